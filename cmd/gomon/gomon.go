@@ -3,12 +3,16 @@ package main
 import (
 	"fmt"
 	"log"
+	"math"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/therceman/gomon/internal/app"
 	"github.com/therceman/gomon/internal/dotenv"
 	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/stats"
 	"github.com/therceman/gomon/internal/types"
 )
 
@@ -24,35 +28,282 @@ func LoadConfig() (types.Config, error) {
 		return types.Config{}, fmt.Errorf("invalid value for FLUSH_TICKER_TIME_SEC")
 	}
 
-	sleepBetweenFetchesMs, err := helpers.ConvertStringToUint16(os.Getenv("SLEEP_BETWEEN_FETCHES_MS"))
-	if err != nil {
-		return types.Config{}, fmt.Errorf("invalid value for SLEEP_BETWEEN_FETCHES_MS")
-	}
-
 	var metricKeys []string
 	keys := os.Getenv("METRIC_KEYS")
 	if keys == "" {
 		metricKeys = []string{
-			"id", "name", "cpu_max_perc", "cpu_avg_perc", "mem_max_mb", "mem_avg_mb", "mem_max_perc", "mem_avg_perc", "disk_mb",
+			"cpu_max_perc", "cpu_avg_perc", "mem_max_mb", "mem_avg_mb", "mem_max_perc", "mem_avg_perc", "disk_mb",
 		}
 	} else {
-		metricKeys = strings.Split(keys, ",")
+		for _, key := range strings.Split(keys, ",") {
+			key = strings.TrimSpace(key)
+			switch key {
+			case "":
+				continue
+			case "id", "name":
+				// Every line is tagged with them, as a field they would clash with the tags
+				log.Printf("Ignoring %s in METRIC_KEYS, it is a tag of every line", key)
+				continue
+			}
+			metricKeys = append(metricKeys, key)
+		}
+	}
+
+	// Metrics whose distribution is exported as histograms, e.g. cpu_perc
+	var histogramMetrics []string
+	if metrics := os.Getenv("HISTOGRAM_METRICS"); metrics != "" {
+		for _, metric := range strings.Split(metrics, ",") {
+			if metric = strings.TrimSpace(metric); metric != "" {
+				histogramMetrics = append(histogramMetrics, metric)
+			}
+		}
+	}
+
+	histogramBuckets, err := parseHistogramBuckets(os.Getenv("HISTOGRAM_BUCKETS"))
+	if err != nil {
+		return types.Config{}, err
+	}
+
+	// Mount points to monitor, discovered from /proc/self/mountinfo when empty
+	var diskMounts []string
+	if mounts := os.Getenv("DISK_MOUNTS"); mounts != "" {
+		for _, mount := range strings.Split(mounts, ",") {
+			if mount = strings.TrimSpace(mount); mount != "" {
+				diskMounts = append(diskMounts, mount)
+			}
+		}
+	}
+
+	diskIOInclude, err := compilePattern("DISKIO_INCLUDE", "")
+	if err != nil {
+		return types.Config{}, err
+	}
+
+	diskIOExclude, err := compilePattern("DISKIO_EXCLUDE", `^(loop|ram)\d+$`)
+	if err != nil {
+		return types.Config{}, err
+	}
+
+	netInclude, err := compilePattern("NET_INCLUDE", "")
+	if err != nil {
+		return types.Config{}, err
+	}
+
+	netExclude, err := compilePattern("NET_EXCLUDE", `^(lo|veth.*)$`)
+	if err != nil {
+		return types.Config{}, err
+	}
+
+	// Socket summaries inside container network namespaces are optional, as they need access to /proc/<pid>/net
+	containerSockets := os.Getenv("CONTAINER_SOCKETS") == "true"
+
+	processes, err := parseProcessMatchers(os.Getenv("PROCESSES"))
+	if err != nil {
+		return types.Config{}, err
+	}
+
+	systemdUnits, err := compilePattern("SYSTEMD_UNITS", "")
+	if err != nil {
+		return types.Config{}, err
+	}
+
+	execCommands, err := parseExecCommands(os.Getenv("EXEC_COMMANDS"))
+	if err != nil {
+		return types.Config{}, err
+	}
+
+	// Commands are killed after the timeout, so a hanging script cannot block the following runs for long
+	execTimeoutSec := uint16(5)
+	if value := os.Getenv("EXEC_TIMEOUT_SEC"); value != "" {
+		execTimeoutSec, err = helpers.ConvertStringToUint16(value)
+		if err != nil || execTimeoutSec == 0 {
+			return types.Config{}, fmt.Errorf("invalid value for EXEC_TIMEOUT_SEC")
+		}
+	}
+
+	// Commands run on the read ticker interval unless they need to run less often
+	execIntervalSec := readTickerTimeSec
+	if value := os.Getenv("EXEC_INTERVAL_SEC"); value != "" {
+		execIntervalSec, err = helpers.ConvertStringToUint16(value)
+		if err != nil || execIntervalSec == 0 {
+			return types.Config{}, fmt.Errorf("invalid value for EXEC_INTERVAL_SEC")
+		}
+	}
+
+	logPatterns, err := parseLogPatterns(os.Getenv("LOG_PATTERNS"))
+	if err != nil {
+		return types.Config{}, err
 	}
 
 	config := types.Config{
-		ContainerName:         os.Getenv("CONTAINER_NAME"),
-		GrafanaInfluxURL:      os.Getenv("GRAFANA_INFLUX_URL"),
-		GrafanaAPIKey:         os.Getenv("GRAFANA_API_KEY"),
-		GrafanaUsername:       os.Getenv("GRAFANA_USERNAME"),
-		ReadTickerTimeSec:     readTickerTimeSec,
-		FlushTickerTimeSec:    flushTickerTimeSec,
-		SleepBetweenFetchesMs: sleepBetweenFetchesMs,
-		MetricKeys:            metricKeys,
+		ContainerName:      os.Getenv("CONTAINER_NAME"),
+		GrafanaInfluxURL:   os.Getenv("GRAFANA_INFLUX_URL"),
+		GrafanaAPIKey:      os.Getenv("GRAFANA_API_KEY"),
+		GrafanaUsername:    os.Getenv("GRAFANA_USERNAME"),
+		ReadTickerTimeSec:  readTickerTimeSec,
+		FlushTickerTimeSec: flushTickerTimeSec,
+		MetricKeys:         metricKeys,
+		DiskMounts:         diskMounts,
+		DiskIOInclude:      diskIOInclude,
+		DiskIOExclude:      diskIOExclude,
+		NetInclude:         netInclude,
+		NetExclude:         netExclude,
+		ContainerSockets:   containerSockets,
+		HostProc:           os.Getenv("HOST_PROC"),
+		HostSys:            os.Getenv("HOST_SYS"),
+		HostRoot:           os.Getenv("HOST_ROOT"),
+		Processes:          processes,
+		SystemdUnits:       systemdUnits,
+		Kubernetes:         os.Getenv("KUBERNETES") == "true",
+		KubeletURL:         os.Getenv("KUBELET_URL"),
+		KubeletTokenFile:   os.Getenv("KUBELET_TOKEN_FILE"),
+		KubeletInsecureTLS: os.Getenv("KUBELET_INSECURE_TLS") == "true",
+		ExecCommands:       execCommands,
+		ExecTimeoutSec:     execTimeoutSec,
+		ExecIntervalSec:    execIntervalSec,
+		TextfileDir:        os.Getenv("TEXTFILE_DIR"),
+		LogPatterns:        logPatterns,
+		HistogramMetrics:   histogramMetrics,
+		HistogramBuckets:   histogramBuckets,
+	}
+
+	// Typos in METRIC_KEYS or HISTOGRAM_METRICS would otherwise silently export nothing
+	if err := stats.ValidateMetricKeys(config); err != nil {
+		return types.Config{}, err
 	}
 
 	return config, nil
 }
 
+// compilePattern compiles the regular expression of an environment variable,
+// falling back to the default when it is not set. An empty pattern yields nil.
+func compilePattern(name string, fallback string) (*regexp.Regexp, error) {
+	pattern, found := os.LookupEnv(name)
+	if !found {
+		pattern = fallback
+	}
+	if pattern == "" {
+		return nil, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for %s: %v", name, err)
+	}
+	return re, nil
+}
+
+// parseProcessMatchers parses semicolon separated matchers in the form <entry>=<kind>:<value>,
+// e.g. nginx=name:nginx;api=cmdline:^python .*api\.py;pg=pidfile:/run/postgresql/postmaster.pid
+func parseProcessMatchers(value string) ([]types.ProcessMatcher, error) {
+	var matchers []types.ProcessMatcher
+	for _, entry := range strings.Split(value, ";") {
+		if entry == "" {
+			continue
+		}
+
+		name, selector, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid value for PROCESSES: %s", entry)
+		}
+		kind, selectorValue, found := strings.Cut(selector, ":")
+		if !found || name == "" || selectorValue == "" {
+			return nil, fmt.Errorf("invalid value for PROCESSES: %s", entry)
+		}
+
+		matcher := types.ProcessMatcher{Name: name, Kind: kind, Value: selectorValue}
+		switch kind {
+		case "name", "pidfile", "cgroup":
+		case "cmdline":
+			pattern, err := regexp.Compile(selectorValue)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for PROCESSES: %s: %v", entry, err)
+			}
+			matcher.Pattern = pattern
+		default:
+			return nil, fmt.Errorf("invalid value for PROCESSES: unknown kind %s", kind)
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers, nil
+}
+
+// parseExecCommands parses semicolon separated <entry>=<format>:<command> definitions
+func parseExecCommands(value string) ([]types.ExecCommand, error) {
+	var commands []types.ExecCommand
+	for _, entry := range strings.Split(value, ";") {
+		if entry == "" {
+			continue
+		}
+
+		name, definition, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid value for EXEC_COMMANDS: %s", entry)
+		}
+		format, command, found := strings.Cut(definition, ":")
+		if !found || name == "" || command == "" {
+			return nil, fmt.Errorf("invalid value for EXEC_COMMANDS: %s", entry)
+		}
+
+		switch format {
+		case "influx", "prometheus":
+		default:
+			return nil, fmt.Errorf("invalid value for EXEC_COMMANDS: unknown format %s", format)
+		}
+		commands = append(commands, types.ExecCommand{Name: name, Format: format, Command: command})
+	}
+	return commands, nil
+}
+
+// parseLogPatterns parses semicolon separated <container>=<counter>:<pattern> definitions
+func parseLogPatterns(value string) ([]types.LogPattern, error) {
+	var patterns []types.LogPattern
+	for _, entry := range strings.Split(value, ";") {
+		if entry == "" {
+			continue
+		}
+
+		container, definition, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid value for LOG_PATTERNS: %s", entry)
+		}
+		name, expression, found := strings.Cut(definition, ":")
+		if !found || container == "" || name == "" || expression == "" {
+			return nil, fmt.Errorf("invalid value for LOG_PATTERNS: %s", entry)
+		}
+
+		pattern, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for LOG_PATTERNS: %s: %v", entry, err)
+		}
+		patterns = append(patterns, types.LogPattern{Container: container, Name: name, Pattern: pattern})
+	}
+	return patterns, nil
+}
+
+// defaultHistogramBuckets fits percentages as well as most millisecond and megabyte metrics
+var defaultHistogramBuckets = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}
+
+// parseHistogramBuckets parses comma separated ascending bucket upper bounds
+func parseHistogramBuckets(value string) ([]float64, error) {
+	if value == "" {
+		return defaultHistogramBuckets, nil
+	}
+
+	var bounds []float64
+	for _, field := range strings.Split(value, ",") {
+		bound, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || math.IsNaN(bound) || math.IsInf(bound, 0) {
+			return nil, fmt.Errorf("invalid value for HISTOGRAM_BUCKETS: %s", field)
+		}
+		if len(bounds) > 0 && bound <= bounds[len(bounds)-1] {
+			return nil, fmt.Errorf("invalid value for HISTOGRAM_BUCKETS: bounds must be ascending")
+		}
+		bounds = append(bounds, bound)
+	}
+	return bounds, nil
+}
+
 func main() {
 	// Load the environment variables from the .env file
 	err := dotenv.LoadEnv(".env")
@@ -8,17 +8,28 @@ import (
 	"time"
 
 	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/hostfs"
 	"github.com/therceman/gomon/internal/stats"
+	"github.com/therceman/gomon/internal/stats/custom"
+	"github.com/therceman/gomon/internal/stats/docker"
+	"github.com/therceman/gomon/internal/stats/kubernetes"
 	"github.com/therceman/gomon/internal/types"
 )
 
 func Run(config types.Config) {
 	log.Println("Running Go Monitor for Container:", config.ContainerName)
 	log.Println("Metric Keys:", config.MetricKeys)
-	log.Printf("Read Ticker Time: %ds, Flush Ticker Time: %ds, Sleep Between Fetches: %dms",
-		config.ReadTickerTimeSec, config.FlushTickerTimeSec, config.SleepBetweenFetchesMs,
+	log.Printf("Read Ticker Time: %ds, Flush Ticker Time: %ds",
+		config.ReadTickerTimeSec, config.FlushTickerTimeSec,
 	)
 
+	// Collectors read the host through these mount points
+	hostfs.Configure(config.HostProc, config.HostSys, config.HostRoot)
+	log.Printf("Host Proc: %s, Host Sys: %s, Host Root: %s", hostfs.ProcDir, hostfs.SysDir, hostfs.RootDir)
+
+	// Sketches and histogram buckets are only kept for the metrics that are exported with them
+	stats.ConfigureAggregation(config)
+
 	pid := helpers.GetCurrentPID()
 	pidStr := helpers.ConvertUint32ToString(pid)
 
@@ -31,7 +42,22 @@ func Run(config types.Config) {
 	defer ticker.Stop()
 	defer flushTicker.Stop()
 
-	statsMap := make(map[string]*types.Stats)
+	// Pods are named through the kubelet when it is configured
+	var kubelet *kubernetes.Kubelet
+	if config.Kubernetes && config.KubeletURL != "" {
+		kubelet = kubernetes.NewKubelet(config.KubeletURL, config.KubeletTokenFile, config.KubeletInsecureTLS)
+	}
+
+	// Commands run in the background, their results are picked up by the read tick
+	var execRunner *custom.ExecRunner
+	if len(config.ExecCommands) > 0 {
+		execTimeout := time.Duration(config.ExecTimeoutSec) * time.Second
+		execInterval := time.Duration(config.ExecIntervalSec) * time.Second
+		execRunner = custom.NewExecRunner(config.ExecCommands, execTimeout, execInterval)
+		execRunner.Start()
+	}
+
+	statsMap := make(map[string]*types.Series)
 
 	for {
 		select {
@@ -40,12 +66,63 @@ func Run(config types.Config) {
 			if systemFetchError != nil {
 				log.Printf("Error fetching system stats: %v", systemFetchError)
 			}
-			time.Sleep(time.Millisecond * 250)
-			dockerFetchError := stats.FetchDockerStats(statsMap)
+			// Container init PIDs are shared by the docker and socket fetches, so docker is only asked once per tick
+			initPIDs, initPIDsError := docker.GetInitPIDs()
+			if initPIDsError != nil {
+				log.Printf("Error getting container init PIDs: %v", initPIDsError)
+			}
+			dockerFetchError := stats.FetchDockerStats(statsMap, config.LogPatterns, initPIDs)
 			if dockerFetchError != nil {
 				log.Printf("Error fetching docker stats: %v", dockerFetchError)
 			}
-			time.Sleep(time.Millisecond * 250)
+			filesystemFetchError := stats.FetchFilesystemStats(statsMap, config.DiskMounts)
+			if filesystemFetchError != nil {
+				log.Printf("Error fetching filesystem stats: %v", filesystemFetchError)
+			}
+			diskIOFetchError := stats.FetchDiskIOStats(statsMap, config.DiskIOInclude, config.DiskIOExclude)
+			if diskIOFetchError != nil {
+				log.Printf("Error fetching disk I/O stats: %v", diskIOFetchError)
+			}
+			networkFetchError := stats.FetchNetworkStats(statsMap, config.NetInclude, config.NetExclude)
+			if networkFetchError != nil {
+				log.Printf("Error fetching network stats: %v", networkFetchError)
+			}
+			socketFetchError := stats.FetchSocketStats(statsMap, initPIDs, config.ContainerSockets)
+			if socketFetchError != nil {
+				log.Printf("Error fetching socket stats: %v", socketFetchError)
+			}
+			sensorFetchError := stats.FetchSensorStats(statsMap)
+			if sensorFetchError != nil {
+				log.Printf("Error fetching sensor stats: %v", sensorFetchError)
+			}
+			processFetchError := stats.FetchProcessStats(statsMap, config.Processes)
+			if processFetchError != nil {
+				log.Printf("Error fetching process stats: %v", processFetchError)
+			}
+			if config.SystemdUnits != nil {
+				systemdFetchError := stats.FetchSystemdStats(statsMap, config.SystemdUnits)
+				if systemdFetchError != nil {
+					log.Printf("Error fetching systemd stats: %v", systemdFetchError)
+				}
+			}
+			if config.Kubernetes {
+				kubernetesFetchError := stats.FetchKubernetesStats(statsMap, kubelet)
+				if kubernetesFetchError != nil {
+					log.Printf("Error fetching kubernetes stats: %v", kubernetesFetchError)
+				}
+			}
+			if execRunner != nil {
+				execFetchError := stats.FetchExecStats(statsMap, execRunner)
+				if execFetchError != nil {
+					log.Printf("Error fetching exec stats: %v", execFetchError)
+				}
+			}
+			if config.TextfileDir != "" {
+				textfileFetchError := stats.FetchTextfileStats(statsMap, config.TextfileDir)
+				if textfileFetchError != nil {
+					log.Printf("Error fetching textfile stats: %v", textfileFetchError)
+				}
+			}
 			workerFetchError := stats.FetchWorkerStats(statsMap, pidStr, pid, "gomon")
 			if workerFetchError != nil {
 				log.Printf("Error fetching worker stats: %v", workerFetchError)
@@ -53,7 +130,7 @@ func Run(config types.Config) {
 			runtime.GC()
 		case <-flushTicker.C:
 			stats.FlushStats(statsMap, config)
-			statsMap = make(map[string]*types.Stats)
+			statsMap = make(map[string]*types.Series)
 			runtime.GC()
 		}
 	}
@@ -0,0 +1,458 @@
+// internal/cgroup/cgroup.go
+
+package cgroup
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/therceman/gomon/internal/hostfs"
+)
+
+// Root returns the mount point of the cgroup filesystem
+func Root() string {
+	return hostfs.Sys("fs", "cgroup")
+}
+
+// IsV2 reports whether the unified cgroup v2 hierarchy is mounted
+func IsV2() bool {
+	_, err := os.Stat(filepath.Join(Root(), "cgroup.controllers"))
+	return err == nil
+}
+
+// Path returns the path of a cgroup file.
+// On cgroup v1 every controller has its own hierarchy, on v2 the controller is ignored.
+func Path(controller string, group string, file string) string {
+	if IsV2() {
+		return filepath.Join(Root(), group, file)
+	}
+	return filepath.Join(Root(), controller, group, file)
+}
+
+// FindContainer returns the cgroup of a Docker container by its full or short ID,
+// supporting both the systemd and the cgroupfs cgroup drivers
+func FindContainer(containerID string) (string, error) {
+	patterns := []string{
+		"system.slice/docker-" + containerID + "*.scope",
+		"docker/" + containerID + "*",
+	}
+
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(Path("cpuacct", pattern, ""))
+		if err != nil {
+			return "", err
+		}
+		if len(matches) == 1 {
+			return filepath.Rel(Path("cpuacct", "", ""), matches[0])
+		}
+	}
+
+	return "", fmt.Errorf("cgroup not found for container %s", containerID)
+}
+
+// FindServices returns the cgroups of the systemd services running in system.slice
+func FindServices() ([]string, error) {
+	matches, err := filepath.Glob(Path("systemd", "system.slice/*.service", ""))
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []string
+	for _, match := range matches {
+		group, err := filepath.Rel(Path("systemd", "", ""), match)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// FindKubernetesContainers returns the cgroups of the containers of Kubernetes pods,
+// supporting both the systemd and the cgroupfs cgroup drivers and every QoS class
+func FindKubernetesContainers() ([]string, error) {
+	patterns := []string{
+		"kubepods/pod*/*",
+		"kubepods/*/pod*/*",
+		"kubepods.slice/kubepods-pod*.slice/*.scope",
+		"kubepods.slice/kubepods-*.slice/kubepods-*-pod*.slice/*.scope",
+	}
+
+	var groups []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(Path("cpuacct", pattern, ""))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			// Skip control files that match the cgroupfs patterns
+			if info, err := os.Stat(match); err != nil || !info.IsDir() {
+				continue
+			}
+			group, err := filepath.Rel(Path("cpuacct", "", ""), match)
+			if err != nil {
+				return nil, err
+			}
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+// ReadMemoryUsage returns the memory used by the cgroup in bytes
+func ReadMemoryUsage(group string) (uint64, error) {
+	if IsV2() {
+		return readUint(Path("memory", group, "memory.current"))
+	}
+	return readUint(Path("memory", group, "memory.usage_in_bytes"))
+}
+
+// MemoryStats holds the breakdown of the memory charged to a cgroup in bytes
+type MemoryStats struct {
+	Usage        uint64
+	Anon         uint64
+	File         uint64
+	Kernel       uint64
+	Shmem        uint64
+	Swap         uint64
+	InactiveFile uint64
+	Limit        uint64 // 0 when unlimited
+}
+
+// unlimitedMemory is the threshold above which a cgroup v1 memory limit means "no limit"
+const unlimitedMemory = 1 << 62
+
+// ReadMemoryStats returns the memory breakdown of the cgroup from memory.stat and its limit
+func ReadMemoryStats(group string) (MemoryStats, error) {
+	usage, err := ReadMemoryUsage(group)
+	if err != nil {
+		return MemoryStats{}, err
+	}
+
+	values, err := ReadFlatKeyed(Path("memory", group, "memory.stat"))
+	if err != nil {
+		return MemoryStats{}, err
+	}
+
+	if IsV2() {
+		stats := MemoryStats{
+			Usage:        usage,
+			Anon:         values["anon"],
+			File:         values["file"],
+			Kernel:       values["kernel"],
+			Shmem:        values["shmem"],
+			InactiveFile: values["inactive_file"],
+		}
+
+		// The kernel total was only added in 5.18, sum up its parts on older kernels
+		if _, found := values["kernel"]; !found {
+			stats.Kernel = values["kernel_stack"] + values["pagetables"] + values["percpu"] + values["sock"] + values["slab"]
+		}
+
+		// Swap accounting may be disabled
+		if swap, err := readUint(Path("memory", group, "memory.swap.current")); err == nil {
+			stats.Swap = swap
+		}
+
+		// memory.max holds "max" when unlimited, which readUint rejects
+		if limit, err := readUint(Path("memory", group, "memory.max")); err == nil {
+			stats.Limit = limit
+		}
+
+		return stats, nil
+	}
+
+	stats := MemoryStats{
+		Usage:        usage,
+		Anon:         values["total_rss"],
+		File:         values["total_cache"],
+		Shmem:        values["total_shmem"],
+		Swap:         values["total_swap"],
+		InactiveFile: values["total_inactive_file"],
+	}
+
+	// Kernel memory accounting is deprecated and may be missing
+	if kernel, err := readUint(Path("memory", group, "memory.kmem.usage_in_bytes")); err == nil {
+		stats.Kernel = kernel
+	}
+
+	if limit, err := readUint(Path("memory", group, "memory.limit_in_bytes")); err == nil && limit < unlimitedMemory {
+		stats.Limit = limit
+	}
+
+	return stats, nil
+}
+
+// MemoryEvents holds the memory event counters of a cgroup since it was created
+type MemoryEvents struct {
+	Max     uint64 // Times the usage hit the limit
+	OOM     uint64 // Times the OOM killer was invoked, not available on cgroup v1
+	OOMKill uint64 // Processes killed by the OOM killer
+}
+
+// ReadMemoryEvents returns the memory event counters of the cgroup
+func ReadMemoryEvents(group string) (MemoryEvents, error) {
+	if IsV2() {
+		values, err := ReadFlatKeyed(Path("memory", group, "memory.events"))
+		if err != nil {
+			return MemoryEvents{}, err
+		}
+		return MemoryEvents{
+			Max:     values["max"],
+			OOM:     values["oom"],
+			OOMKill: values["oom_kill"],
+		}, nil
+	}
+
+	failcnt, err := readUint(Path("memory", group, "memory.failcnt"))
+	if err != nil {
+		return MemoryEvents{}, err
+	}
+
+	// oom_kill is only listed in memory.oom_control since kernel 4.13
+	values, err := ReadFlatKeyed(Path("memory", group, "memory.oom_control"))
+	if err != nil {
+		return MemoryEvents{}, err
+	}
+
+	return MemoryEvents{
+		Max:     failcnt,
+		OOMKill: values["oom_kill"],
+	}, nil
+}
+
+// ReadPIDsCurrent returns the number of tasks in the cgroup
+func ReadPIDsCurrent(group string) (uint64, error) {
+	return readUint(Path("pids", group, "pids.current"))
+}
+
+// ReadPIDsMax returns the maximum number of tasks allowed in the cgroup, 0 when unlimited
+func ReadPIDsMax(group string) (uint64, error) {
+	data, err := os.ReadFile(Path("pids", group, "pids.max"))
+	if err != nil {
+		return 0, err
+	}
+
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// ReadIOBytes returns the bytes read and written by the cgroup over all devices
+func ReadIOBytes(group string) (readBytes uint64, writeBytes uint64, err error) {
+	if IsV2() {
+		// Lines look like "8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0"
+		data, err := os.ReadFile(Path("io", group, "io.stat"))
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, field := range strings.Fields(string(data)) {
+			key, value, found := strings.Cut(field, "=")
+			if !found {
+				continue
+			}
+			counter, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "rbytes":
+				readBytes += counter
+			case "wbytes":
+				writeBytes += counter
+			}
+		}
+		return readBytes, writeBytes, nil
+	}
+
+	// Lines look like "8:0 Read 1459200", followed by a "Total" line
+	data, err := os.ReadFile(Path("blkio", group, "blkio.throttle.io_service_bytes"))
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		counter, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[1] {
+		case "Read":
+			readBytes += counter
+		case "Write":
+			writeBytes += counter
+		}
+	}
+	return readBytes, writeBytes, nil
+}
+
+// ReadCPUUsage returns the total CPU time consumed by the cgroup in microseconds
+func ReadCPUUsage(group string) (uint64, error) {
+	if IsV2() {
+		values, err := ReadFlatKeyed(Path("cpu", group, "cpu.stat"))
+		if err != nil {
+			return 0, err
+		}
+		usage, found := values["usage_usec"]
+		if !found {
+			return 0, fmt.Errorf("usage_usec not found in cpu.stat of %s", group)
+		}
+		return usage, nil
+	}
+
+	data, err := os.ReadFile(Path("cpuacct", group, "cpuacct.usage"))
+	if err != nil {
+		return 0, err
+	}
+	usage, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return usage / 1000, nil // Convert ns to us
+}
+
+// CPUThrottling holds the CFS bandwidth counters of a cgroup
+type CPUThrottling struct {
+	Periods       uint64 // Enforcement periods that have elapsed
+	Throttled     uint64 // Periods in which the cgroup was throttled
+	ThrottledUsec uint64 // Total time the cgroup was throttled in microseconds
+}
+
+// ReadCPUThrottling returns the CFS bandwidth counters of the cgroup
+func ReadCPUThrottling(group string) (CPUThrottling, error) {
+	values, err := ReadFlatKeyed(Path("cpu", group, "cpu.stat"))
+	if err != nil {
+		return CPUThrottling{}, err
+	}
+
+	if IsV2() {
+		return CPUThrottling{
+			Periods:       values["nr_periods"],
+			Throttled:     values["nr_throttled"],
+			ThrottledUsec: values["throttled_usec"],
+		}, nil
+	}
+
+	return CPUThrottling{
+		Periods:       values["nr_periods"],
+		Throttled:     values["nr_throttled"],
+		ThrottledUsec: values["throttled_time"] / 1000, // Convert ns to us
+	}, nil
+}
+
+// ReadCPUQuota returns the number of cores the cgroup may use per period, 0 when unlimited
+func ReadCPUQuota(group string) (float64, error) {
+	var quota, period string
+
+	if IsV2() {
+		// cpu.max looks like "200000 100000" or "max 100000"
+		data, err := os.ReadFile(Path("cpu", group, "cpu.max"))
+		if err != nil {
+			return 0, err
+		}
+		fields := strings.Fields(string(data))
+		if len(fields) != 2 {
+			return 0, fmt.Errorf("unexpected format in cpu.max of %s", group)
+		}
+		quota, period = fields[0], fields[1]
+	} else {
+		quotaData, err := os.ReadFile(Path("cpu", group, "cpu.cfs_quota_us"))
+		if err != nil {
+			return 0, err
+		}
+		periodData, err := os.ReadFile(Path("cpu", group, "cpu.cfs_period_us"))
+		if err != nil {
+			return 0, err
+		}
+		quota, period = strings.TrimSpace(string(quotaData)), strings.TrimSpace(string(periodData))
+	}
+
+	// v2 writes "max" and v1 writes -1 when there is no limit
+	if quota == "max" || quota == "-1" {
+		return 0, nil
+	}
+
+	quotaUsec, err := strconv.ParseUint(quota, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	periodUsec, err := strconv.ParseUint(period, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if periodUsec == 0 {
+		return 0, nil
+	}
+
+	return float64(quotaUsec) / float64(periodUsec), nil
+}
+
+// ReadProcs returns the PIDs of the processes that are members of the cgroup
+func ReadProcs(group string) ([]uint32, error) {
+	data, err := os.ReadFile(Path("systemd", group, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []uint32
+	for _, field := range strings.Fields(string(data)) {
+		pid, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		pids = append(pids, uint32(pid))
+	}
+
+	return pids, nil
+}
+
+// readUint reads a cgroup file holding a single number
+func readUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// ReadFlatKeyed parses cgroup files made of "key value" lines such as cpu.stat or memory.stat
+func ReadFlatKeyed(path string) (values map[string]uint64, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	values = make(map[string]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue // Skip values such as "max"
+		}
+		values[fields[0]] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	return values, nil
+}
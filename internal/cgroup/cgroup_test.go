@@ -0,0 +1,146 @@
+// internal/cgroup/cgroup_test.go
+
+package cgroup
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/therceman/gomon/internal/hostfs/hostfstest"
+)
+
+// fakeSysfs points the host sysfs to a temporary directory holding the given cgroup files
+func fakeSysfs(t *testing.T, files map[string]string) {
+	t.Helper()
+	cgroupFiles := make(map[string]string, len(files))
+	for name, content := range files {
+		cgroupFiles["fs/cgroup/"+name] = content
+	}
+	hostfstest.FakeSys(t, cgroupFiles)
+}
+
+func TestReadV2(t *testing.T) {
+	group := "system.slice/nginx.service"
+	fakeSysfs(t, map[string]string{
+		"cgroup.controllers":                      "cpu io memory pids\n",
+		group + "/cpu.stat":                       "usage_usec 5000\nuser_usec 3000\nsystem_usec 2000\nnr_periods 10\nnr_throttled 2\nthrottled_usec 700\n",
+		group + "/cpu.max":                        "150000 100000\n",
+		group + "/memory.current":                 "4096000\n",
+		group + "/memory.stat":                    "anon 1000\nfile 2000\nkernel_stack 10\npagetables 20\npercpu 30\nsock 40\nslab 50\nshmem 60\ninactive_file 70\n",
+		group + "/memory.max":                     "max\n",
+		group + "/pids.current":                   "7\n",
+		group + "/io.stat":                        "8:0 rbytes=100 wbytes=200 rios=1 wios=2 dbytes=0 dios=0\n8:16 rbytes=10 wbytes=20 rios=1 wios=1 dbytes=0 dios=0\n",
+		"system.slice/ssh.service/cgroup.procs":   "1\n",
+		"system.slice/docker.socket/cgroup.procs": "",
+	})
+
+	if !IsV2() {
+		t.Fatal("IsV2() = false, expected true")
+	}
+
+	usage, err := ReadCPUUsage(group)
+	if err != nil || usage != 5000 {
+		t.Errorf("ReadCPUUsage() = %d, %v, expected 5000", usage, err)
+	}
+
+	throttling, err := ReadCPUThrottling(group)
+	expectedThrottling := CPUThrottling{Periods: 10, Throttled: 2, ThrottledUsec: 700}
+	if err != nil || throttling != expectedThrottling {
+		t.Errorf("ReadCPUThrottling() = %+v, %v, expected %+v", throttling, err, expectedThrottling)
+	}
+
+	quota, err := ReadCPUQuota(group)
+	if err != nil || quota != 1.5 {
+		t.Errorf("ReadCPUQuota() = %v, %v, expected 1.5", quota, err)
+	}
+
+	// The kernel total is summed up from its parts when memory.stat lacks it
+	memory, err := ReadMemoryStats(group)
+	expectedMemory := MemoryStats{Usage: 4096000, Anon: 1000, File: 2000, Kernel: 150, Shmem: 60, InactiveFile: 70}
+	if err != nil || memory != expectedMemory {
+		t.Errorf("ReadMemoryStats() = %+v, %v, expected %+v", memory, err, expectedMemory)
+	}
+
+	tasks, err := ReadPIDsCurrent(group)
+	if err != nil || tasks != 7 {
+		t.Errorf("ReadPIDsCurrent() = %d, %v, expected 7", tasks, err)
+	}
+
+	readBytes, writeBytes, err := ReadIOBytes(group)
+	if err != nil || readBytes != 110 || writeBytes != 220 {
+		t.Errorf("ReadIOBytes() = %d, %d, %v, expected 110, 220", readBytes, writeBytes, err)
+	}
+
+	services, err := FindServices()
+	expectedServices := []string{"system.slice/nginx.service", "system.slice/ssh.service"}
+	if err != nil || !reflect.DeepEqual(services, expectedServices) {
+		t.Errorf("FindServices() = %v, %v, expected %v", services, err, expectedServices)
+	}
+}
+
+func TestReadV1(t *testing.T) {
+	group := "system.slice/nginx.service"
+	fakeSysfs(t, map[string]string{
+		"cpuacct/" + group + "/cpuacct.usage":                 "5000000\n",
+		"cpu/" + group + "/cpu.stat":                          "nr_periods 10\nnr_throttled 2\nthrottled_time 700000\n",
+		"cpu/" + group + "/cpu.cfs_quota_us":                  "-1\n",
+		"cpu/" + group + "/cpu.cfs_period_us":                 "100000\n",
+		"memory/" + group + "/memory.usage_in_bytes":          "4096000\n",
+		"memory/" + group + "/memory.stat":                    "rss 1\ntotal_rss 1000\ntotal_cache 2000\ntotal_shmem 60\ntotal_inactive_file 70\n",
+		"memory/" + group + "/memory.kmem.usage_in_bytes":     "150\n",
+		"memory/" + group + "/memory.limit_in_bytes":          "9223372036854771712\n",
+		"pids/" + group + "/pids.current":                     "7\n",
+		"blkio/" + group + "/blkio.throttle.io_service_bytes": "8:0 Read 100\n8:0 Write 200\n8:0 Total 300\n8:16 Read 10\n8:16 Write 20\n8:16 Total 30\nTotal 330\n",
+		"systemd/system.slice/ssh.service/cgroup.procs":       "1\n",
+		"systemd/system.slice/nginx.service/cgroup.procs":     "2\n3\n",
+	})
+
+	if IsV2() {
+		t.Fatal("IsV2() = true, expected false")
+	}
+
+	// cpuacct.usage is in nanoseconds
+	usage, err := ReadCPUUsage(group)
+	if err != nil || usage != 5000 {
+		t.Errorf("ReadCPUUsage() = %d, %v, expected 5000", usage, err)
+	}
+
+	throttling, err := ReadCPUThrottling(group)
+	expectedThrottling := CPUThrottling{Periods: 10, Throttled: 2, ThrottledUsec: 700}
+	if err != nil || throttling != expectedThrottling {
+		t.Errorf("ReadCPUThrottling() = %+v, %v, expected %+v", throttling, err, expectedThrottling)
+	}
+
+	quota, err := ReadCPUQuota(group)
+	if err != nil || quota != 0 {
+		t.Errorf("ReadCPUQuota() = %v, %v, expected 0", quota, err)
+	}
+
+	// The huge v1 limit means unlimited
+	memory, err := ReadMemoryStats(group)
+	expectedMemory := MemoryStats{Usage: 4096000, Anon: 1000, File: 2000, Kernel: 150, Shmem: 60, InactiveFile: 70}
+	if err != nil || memory != expectedMemory {
+		t.Errorf("ReadMemoryStats() = %+v, %v, expected %+v", memory, err, expectedMemory)
+	}
+
+	tasks, err := ReadPIDsCurrent(group)
+	if err != nil || tasks != 7 {
+		t.Errorf("ReadPIDsCurrent() = %d, %v, expected 7", tasks, err)
+	}
+
+	readBytes, writeBytes, err := ReadIOBytes(group)
+	if err != nil || readBytes != 110 || writeBytes != 220 {
+		t.Errorf("ReadIOBytes() = %d, %d, %v, expected 110, 220", readBytes, writeBytes, err)
+	}
+
+	procs, err := ReadProcs(group)
+	if err != nil || !reflect.DeepEqual(procs, []uint32{2, 3}) {
+		t.Errorf("ReadProcs() = %v, %v, expected [2 3]", procs, err)
+	}
+
+	services, err := FindServices()
+	expectedServices := []string{"system.slice/nginx.service", "system.slice/ssh.service"}
+	if err != nil || !reflect.DeepEqual(services, expectedServices) {
+		t.Errorf("FindServices() = %v, %v, expected %v", services, err, expectedServices)
+	}
+}
@@ -0,0 +1,41 @@
+// internal/hostfs/hostfs.go
+
+package hostfs
+
+import "path/filepath"
+
+// Mount points of the host procfs, sysfs and root filesystem.
+// They differ from the defaults when gomon runs in a container with the host mounted, e.g. at /host.
+var (
+	ProcDir = "/proc"
+	SysDir  = "/sys"
+	RootDir = "/"
+)
+
+// Configure sets the host mount points, empty values keep the defaults
+func Configure(procDir string, sysDir string, rootDir string) {
+	if procDir != "" {
+		ProcDir = procDir
+	}
+	if sysDir != "" {
+		SysDir = sysDir
+	}
+	if rootDir != "" {
+		RootDir = rootDir
+	}
+}
+
+// Proc returns a path below the host procfs, e.g. Proc("meminfo")
+func Proc(elem ...string) string {
+	return filepath.Join(append([]string{ProcDir}, elem...)...)
+}
+
+// Sys returns a path below the host sysfs, e.g. Sys("class", "hwmon")
+func Sys(elem ...string) string {
+	return filepath.Join(append([]string{SysDir}, elem...)...)
+}
+
+// Root returns a path below the host root filesystem, e.g. Root("var", "lib", "docker")
+func Root(elem ...string) string {
+	return filepath.Join(append([]string{RootDir}, elem...)...)
+}
@@ -0,0 +1,40 @@
+// internal/hostfs/hostfstest/hostfstest.go
+
+// Package hostfstest provides fake host filesystems for tests
+package hostfstest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/therceman/gomon/internal/hostfs"
+)
+
+// WriteFiles creates the files below root, keyed by their path relative to it
+func WriteFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// FakeSys points the host sysfs to a temporary directory holding the given files until the test ends
+// and returns the directory
+func FakeSys(t *testing.T, files map[string]string) string {
+	t.Helper()
+	sysDir := t.TempDir()
+	WriteFiles(t, sysDir, files)
+
+	previous := hostfs.SysDir
+	hostfs.SysDir = sysDir
+	t.Cleanup(func() { hostfs.SysDir = previous })
+
+	return sysDir
+}
@@ -0,0 +1,115 @@
+// internal/psi/psi.go
+
+package psi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/stats/counter"
+)
+
+// Line holds one line of a pressure file, e.g. "some avg10=0.00 avg60=0.00 avg300=0.00 total=0"
+type Line struct {
+	Avg10  float32 `json:"avg10"`  // Share of time stalled over the last 10 seconds
+	Avg60  float32 `json:"avg60"`  // Share of time stalled over the last 60 seconds
+	Avg300 float32 `json:"avg300"` // Share of time stalled over the last 300 seconds
+	Total  uint64  `json:"total"`  // Total stall time in microseconds
+}
+
+// Read parses a pressure file into its "some" and "full" lines
+func Read(path string) (lines map[string]Line, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	lines = make(map[string]Line)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var line Line
+		for _, field := range fields[1:] {
+			key, value, found := strings.Cut(field, "=")
+			if !found {
+				return nil, fmt.Errorf("unexpected format in %s", path)
+			}
+
+			switch key {
+			case "avg10":
+				line.Avg10, err = helpers.ConvertStringToFloat32(value)
+			case "avg60":
+				line.Avg60, err = helpers.ConvertStringToFloat32(value)
+			case "avg300":
+				line.Avg300, err = helpers.ConvertStringToFloat32(value)
+			case "total":
+				line.Total, err = strconv.ParseUint(value, 10, 64)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		lines[fields[0]] = line
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	return lines, nil
+}
+
+// MetricPattern matches the names of the metrics returned by Collect
+var MetricPattern = regexp.MustCompile(`^psi_(cpu|memory|io)_(some|full)_(avg10|avg60|stall)_perc$`)
+
+// Collect reads the pressure files keyed by resource (cpu, memory, io) and returns metrics
+// such as psi_cpu_some_avg10_perc. The stall percentage over the read interval is derived from the
+// stall totals, which are kept in counters under the given key prefix, e.g. the container ID.
+// Missing files are skipped, as PSI may be disabled in the kernel. Files that cannot be read are skipped
+// as well and their errors returned along with the metrics of the other files.
+func Collect(files map[string]string, counters *counter.Set, prefix string) (map[string]float32, error) {
+	metrics := make(map[string]float32)
+	now := time.Now()
+
+	var errs []error
+	for resource, path := range files {
+		lines, err := Read(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for kind, line := range lines {
+			name := "psi_" + resource + "_" + kind
+			metrics[name+"_avg10_perc"] = line.Avg10
+			metrics[name+"_avg60_perc"] = line.Avg60
+
+			// Microseconds stalled per second, as a share of the second
+			if _, usecPerSec, ok := counters.Update(prefix+"/"+name, line.Total, now); ok {
+				metrics[name+"_stall_perc"] = helpers.RoundToTwoDecimal(usecPerSec / 1e4)
+			}
+		}
+	}
+
+	return metrics, errors.Join(errs...)
+}
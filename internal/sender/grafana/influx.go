@@ -7,43 +7,193 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/therceman/gomon/internal/helpers"
 	"github.com/therceman/gomon/internal/types"
 )
 
-// PrepareInfluxData formats the stats data according to the specified metric keys.
-func PrepareInfluxData(metricKeys []string, cont string, stats types.Stats) string {
+// PrepareInfluxData formats the series data according to the specified metric keys.
+// It returns an empty string when none of the keys apply to the series.
+func PrepareInfluxData(metricKeys []string, cont string, series types.Series) string {
 	prefix := "gomon"
 
+	// Metrics are only exported by series that collected them
 	var values []string
 	for _, key := range metricKeys {
-		switch key {
-		case "cpu_max_perc":
-			values = append(values, fmt.Sprintf("cpu_max_perc=%.2f", stats.CPUMaxPerc))
-		case "cpu_avg_perc":
-			values = append(values, fmt.Sprintf("cpu_avg_perc=%.2f", stats.CPUAvgPerc))
-		case "mem_max_mb":
-			values = append(values, fmt.Sprintf("mem_max_mb=%.2f", stats.MemMaxMB))
-		case "mem_avg_mb":
-			values = append(values, fmt.Sprintf("mem_avg_mb=%.2f", stats.MemAvgMB))
-		case "mem_max_perc":
-			values = append(values, fmt.Sprintf("mem_max_perc=%.2f", stats.MemMaxPerc))
-		case "mem_avg_perc":
-			values = append(values, fmt.Sprintf("mem_avg_perc=%.2f", stats.MemAvgPerc))
-		case "disk_mb":
-			values = append(values, fmt.Sprintf("disk_mb=%.2f", stats.DiskMB))
+		if value, found := metricValue(key, series); found {
+			values = append(values, fmt.Sprintf("%s=%.2f", key, value))
 		}
 	}
 
+	// A line without fields is not valid line protocol
+	if len(values) == 0 {
+		return ""
+	}
+
 	dataLine := fmt.Sprintf(
-		"%s,cont=%s,group=%s,id=%s,name=%s %s",
-		prefix, cont, stats.Group, stats.ID, stats.Name, strings.Join(values, ","),
+		"%s,cont=%s,group=%s,id=%s,name=%s%s %s",
+		prefix, cont, series.Group, escapeTag(series.ID), escapeTag(series.Name), formatTags(series.Tags), strings.Join(values, ","),
 	)
 
 	return dataLine
 }
 
+// reservedTags are the tags written on every line before the additional tags of a series,
+// including those of the histogram lines
+var reservedTags = map[string]bool{"cont": true, "group": true, "id": true, "name": true, "metric": true, "le": true}
+
+// IsReservedTag reports whether an additional tag of a series would clash with a tag written on every line
+func IsReservedTag(key string) bool {
+	return reservedTags[key]
+}
+
+// formatTags formats additional tags sorted by key, as recommended for line protocol
+func formatTags(tags map[string]string) string {
+	tagKeys := make([]string, 0, len(tags))
+	for key := range tags {
+		tagKeys = append(tagKeys, key)
+	}
+	sort.Strings(tagKeys)
+
+	var formatted strings.Builder
+	for _, key := range tagKeys {
+		// Line protocol has no empty tag values, a tag without value is left out instead
+		if tags[key] == "" {
+			continue
+		}
+		formatted.WriteString("," + escapeTag(key) + "=" + escapeTag(tags[key]))
+	}
+	return formatted.String()
+}
+
+// escapeTag escapes the characters that have a special meaning in line protocol tag keys and values
+func escapeTag(value string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(value)
+}
+
+// metricValue resolves a key such as cpu_user_max_perc to the aggregated value of the cpu_user_perc metric.
+// The aggregates are min, max, avg, stddev, sum, first, last, count (samples in the window), duration (seconds from
+// the first to the last sample) and the percentiles p50, p90, p95 or p99, e.g. cpu_p95_perc.
+// A key without aggregate, such as disk_mb, resolves to the last value of a gauge and to the sum of a counter.
+func metricValue(key string, series types.Series) (float32, bool) {
+	parts := strings.Split(key, "_")
+	for i := len(parts) - 1; i >= 0; i-- {
+		percentile, isPercentile := parsePercentile(parts[i])
+		if !isPercentile && !aggregates[parts[i]] {
+			continue
+		}
+
+		// Metric names may contain an aggregate word themselves, e.g. proc_count
+		name := strings.Join(append(parts[:i:i], parts[i+1:]...), "_")
+		metric, found := series.Metrics[name]
+		if !found {
+			continue
+		}
+		switch parts[i] {
+		case "min":
+			return metric.Min, true
+		case "max":
+			return metric.Max, true
+		case "avg":
+			return metric.Avg, true
+		case "stddev":
+			return metric.StdDev, true
+		case "sum":
+			return metric.Sum, true
+		case "first":
+			return metric.First, true
+		case "last":
+			return metric.Last, true
+		case "count":
+			return float32(metric.Count), true
+		case "duration":
+			return metric.Duration, true
+		default:
+			// Only the metrics selected with a percentile in METRIC_KEYS keep a sketch
+			if metric.Sketch == nil {
+				return 0, false
+			}
+			return helpers.RoundToTwoDecimal(float32(metric.Sketch.Quantile(percentile))), true
+		}
+	}
+
+	metric, found := series.Metrics[key]
+	if !found {
+		return 0, false
+	}
+	if metric.Kind == types.Counter {
+		return metric.Sum, true
+	}
+	return metric.Last, true
+}
+
+// aggregates are the words selecting an aggregate of a metric besides the percentiles
+var aggregates = map[string]bool{
+	"min": true, "max": true, "avg": true, "stddev": true, "sum": true, "first": true, "last": true, "count": true,
+	"duration": true,
+}
+
+// IsAggregate reports whether a word of a metric key selects an aggregate, e.g. max or p95
+func IsAggregate(token string) bool {
+	_, isPercentile := parsePercentile(token)
+	return isPercentile || aggregates[token]
+}
+
+// PercentileMetric returns the name of the metric a key selects a percentile of, e.g. cpu_perc for cpu_p95_perc
+func PercentileMetric(key string) (string, bool) {
+	parts := strings.Split(key, "_")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if _, isPercentile := parsePercentile(parts[i]); isPercentile {
+			return strings.Join(append(parts[:i:i], parts[i+1:]...), "_"), true
+		}
+	}
+	return "", false
+}
+
+// parsePercentile parses a percentile token such as p95 into the quantile 0.95
+func parsePercentile(token string) (float64, bool) {
+	if len(token) < 2 || len(token) > 3 || token[0] != 'p' {
+		return 0, false
+	}
+	percentile, err := strconv.Atoi(token[1:])
+	if err != nil || percentile < 1 || percentile > 99 {
+		return 0, false
+	}
+	return float64(percentile) / 100, true
+}
+
+// PrepareInfluxHistograms formats the distribution of the given metrics of the series as histograms. Like Telegraf
+// does for Prometheus histograms, every bucket is a line tagged with its upper bound as le and holding the cumulative
+// count of the samples up to it, followed by a line with the count and sum of all samples.
+func PrepareInfluxHistograms(metricNames []string, bounds []float64, cont string, series types.Series) []string {
+	var lines []string
+	for _, name := range metricNames {
+		metric, found := series.Metrics[name]
+		if !found || len(metric.Buckets) != len(bounds) {
+			continue
+		}
+
+		prefix := fmt.Sprintf(
+			"gomon_histogram,cont=%s,group=%s,id=%s,name=%s,metric=%s%s",
+			cont, series.Group, escapeTag(series.ID), escapeTag(series.Name), escapeTag(name), formatTags(series.Tags),
+		)
+
+		var cumulative uint32
+		for i, bound := range bounds {
+			cumulative += metric.Buckets[i]
+			lines = append(lines, fmt.Sprintf("%s,le=%s bucket=%di", prefix, strconv.FormatFloat(bound, 'g', -1, 64), cumulative))
+		}
+		lines = append(lines,
+			fmt.Sprintf("%s,le=+Inf bucket=%di", prefix, metric.Count),
+			fmt.Sprintf("%s count=%di,sum=%.2f", prefix, metric.Count, metric.Sum),
+		)
+	}
+	return lines
+}
+
 // SendToInflux sends the prepared data to InfluxDB.
 func _(url string, username string, apiKey string, data string) error {
 	req, err := http.NewRequest("POST", url, strings.NewReader(data))
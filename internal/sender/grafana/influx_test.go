@@ -0,0 +1,81 @@
+// internal/sender/grafana/influx_test.go
+
+package grafana
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/therceman/gomon/internal/types"
+)
+
+func TestFormatTags(t *testing.T) {
+	tags := map[string]string{
+		"unit":       "nginx.service",
+		"mount path": "/mnt/my data",
+		"a=b,c":      "x=y,z",
+		"empty":      "",
+	}
+
+	expected := `,a\=b\,c=x\=y\,z,mount\ path=/mnt/my\ data,unit=nginx.service`
+	if formatted := formatTags(tags); formatted != expected {
+		t.Errorf("formatTags() = %s, expected %s", formatted, expected)
+	}
+
+	if formatted := formatTags(nil); formatted != "" {
+		t.Errorf("formatTags(nil) = %s, expected an empty string", formatted)
+	}
+}
+
+func TestMetricValue(t *testing.T) {
+	series := types.Series{Metrics: map[string]*types.Metric{
+		"cpu_perc":   {Kind: types.Gauge, Max: 90, Last: 10, Sum: 100},
+		"log_errors": {Kind: types.Counter, Max: 3, Last: 1, Sum: 5},
+		"proc_count": {Kind: types.Gauge, Last: 7, Count: 2},
+	}}
+
+	tests := []struct {
+		key      string
+		expected float32
+		found    bool
+	}{
+		{"cpu_perc", 10, true},
+		{"cpu_max_perc", 90, true},
+		{"log_errors", 5, true},
+		{"log_errors_max", 3, true},
+		{"proc_count", 7, true},
+		{"proc_count_count", 2, true},
+		{"mem_mb", 0, false},
+	}
+	for _, test := range tests {
+		value, found := metricValue(test.key, series)
+		if value != test.expected || found != test.found {
+			t.Errorf("metricValue(%s) = %v, %v, expected %v, %v", test.key, value, found, test.expected, test.found)
+		}
+	}
+}
+
+func TestPrepareInfluxHistograms(t *testing.T) {
+	series := types.Series{
+		ID:    "system",
+		Name:  "system",
+		Group: "system",
+		Metrics: map[string]*types.Metric{
+			"cpu_perc": {Count: 4, Sum: 565, Buckets: []uint32{2, 1}},
+			"load1":    {Count: 1, Sum: 1},
+		},
+	}
+
+	lines := PrepareInfluxHistograms([]string{"cpu_perc", "load1", "mem_mb"}, []float64{10, 100}, "web", series)
+
+	prefix := "gomon_histogram,cont=web,group=system,id=system,name=system,metric=cpu_perc"
+	expected := []string{
+		prefix + ",le=10 bucket=2i",
+		prefix + ",le=100 bucket=3i",
+		prefix + ",le=+Inf bucket=4i",
+		prefix + " count=4i,sum=565.00",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("PrepareInfluxHistograms() = %q, expected %q", lines, expected)
+	}
+}
@@ -0,0 +1,159 @@
+// internal/sketch/sketch.go
+
+package sketch
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// relativeAccuracy is the maximum relative error of the quantiles, 1% of the value
+	relativeAccuracy = 0.01
+	// maxBuckets bounds the memory of a sketch, the buckets of the lowest values are merged beyond it
+	maxBuckets = 512
+	// minIndexable is the smallest magnitude that gets its own bucket, smaller values count as zero
+	minIndexable = 1e-9
+)
+
+var (
+	gamma    = (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	logGamma = math.Log(gamma)
+)
+
+// Sketch estimates quantiles of a stream of values in bounded memory. Values are counted in exponentially
+// growing buckets (gamma^(i-1), gamma^i], so every quantile is within the relative accuracy of the true value.
+type Sketch struct {
+	positive map[int]uint32 // Bucket counts of positive values keyed by index
+	negative map[int]uint32 // Bucket counts of negative values keyed by the index of their magnitude
+	zero     uint32         // Count of values too small to index
+	count    uint32
+	sum      float64
+}
+
+// New creates an empty sketch
+func New() *Sketch {
+	return &Sketch{
+		positive: make(map[int]uint32),
+		negative: make(map[int]uint32),
+	}
+}
+
+// Add adds a value to the sketch
+func (s *Sketch) Add(value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return
+	}
+
+	s.count++
+	s.sum += value
+
+	switch {
+	case value > minIndexable:
+		s.positive[index(value)]++
+	case value < -minIndexable:
+		s.negative[index(-value)]++
+	default:
+		s.zero++
+	}
+
+	if len(s.positive)+len(s.negative) > maxBuckets {
+		s.collapse()
+	}
+}
+
+// Count returns the number of values added to the sketch
+func (s *Sketch) Count() uint32 {
+	return s.count
+}
+
+// Sum returns the sum of the values added to the sketch
+func (s *Sketch) Sum() float64 {
+	return s.sum
+}
+
+// Quantile returns the estimated value at quantile q between 0 and 1, 0 for an empty sketch
+func (s *Sketch) Quantile(q float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+
+	rank := uint32(q * float64(s.count-1))
+	var seen uint32
+	for _, bucket := range s.ordered() {
+		seen += bucket.count
+		if seen > rank {
+			return bucket.value
+		}
+	}
+	return 0
+}
+
+// orderedBucket is a bucket with the value representing it, the midpoint that keeps the relative error bounded
+type orderedBucket struct {
+	value float64
+	count uint32
+}
+
+// ordered returns the non-empty buckets from the most negative to the most positive values
+func (s *Sketch) ordered() []orderedBucket {
+	buckets := make([]orderedBucket, 0, len(s.negative)+len(s.positive)+1)
+
+	for _, i := range sortedKeys(s.negative, true) {
+		buckets = append(buckets, orderedBucket{value: -value(i), count: s.negative[i]})
+	}
+	if s.zero > 0 {
+		buckets = append(buckets, orderedBucket{value: 0, count: s.zero})
+	}
+	for _, i := range sortedKeys(s.positive, false) {
+		buckets = append(buckets, orderedBucket{value: value(i), count: s.positive[i]})
+	}
+
+	return buckets
+}
+
+// collapse merges the bucket of the lowest values into the next one on the side that has the most buckets,
+// i.e. the smallest positive or the most negative values, which only loses accuracy for the lowest quantiles
+func (s *Sketch) collapse() {
+	side, negative := s.positive, false
+	if len(s.negative) > len(s.positive) {
+		side, negative = s.negative, true
+	}
+
+	// Negative buckets are keyed by magnitude, so their lowest values have the highest keys
+	keys := sortedKeys(side, negative)
+	if len(keys) < 2 {
+		return
+	}
+	side[keys[1]] += side[keys[0]]
+	delete(side, keys[0])
+}
+
+// index returns the bucket index of a positive value
+func index(value float64) int {
+	return int(math.Ceil(math.Log(value) / logGamma))
+}
+
+// upperBound returns the largest value of bucket i
+func upperBound(i int) float64 {
+	return math.Pow(gamma, float64(i))
+}
+
+// value returns the value representing bucket i
+func value(i int) float64 {
+	return 2 * upperBound(i) / (gamma + 1)
+}
+
+// sortedKeys returns the bucket indexes in ascending or descending order
+func sortedKeys(buckets map[int]uint32, descending bool) []int {
+	keys := make([]int, 0, len(buckets))
+	for key := range buckets {
+		keys = append(keys, key)
+	}
+	if descending {
+		sort.Sort(sort.Reverse(sort.IntSlice(keys)))
+	} else {
+		sort.Ints(keys)
+	}
+	return keys
+}
@@ -0,0 +1,109 @@
+// internal/sketch/sketch_test.go
+
+package sketch
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// checkQuantiles compares the quantiles of the sketch with the exact ones of the values, which have to be
+// within the relative accuracy
+func checkQuantiles(t *testing.T, s *Sketch, values []float64, quantiles []float64) {
+	t.Helper()
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	for _, q := range quantiles {
+		exact := sorted[int(q*float64(len(sorted)-1))]
+		estimate := s.Quantile(q)
+		if math.Abs(estimate-exact) > relativeAccuracy*math.Abs(exact)+1e-12 {
+			t.Errorf("Quantile(%v) = %v, expected %v within %v%%", q, estimate, exact, relativeAccuracy*100)
+		}
+	}
+}
+
+var quantiles = []float64{0, 0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.95, 0.99, 1}
+
+func TestQuantile(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	s := New()
+	var values []float64
+	for i := 0; i < 10000; i++ {
+		// Spread over four orders of magnitude, which fits within the bucket limit
+		value := math.Exp(random.Float64()*9 - 3)
+		values = append(values, value)
+		s.Add(value)
+	}
+
+	checkQuantiles(t, s, values, quantiles)
+
+	if s.Count() != 10000 {
+		t.Errorf("Count() = %d, expected 10000", s.Count())
+	}
+}
+
+func TestQuantileNegativeAndZero(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	s := New()
+	var values []float64
+	for i := 0; i < 3000; i++ {
+		// Temperatures below zero or clock offsets are negative
+		value := random.NormFloat64() * 50
+		if i%10 == 0 {
+			value = 0
+		}
+		values = append(values, value)
+		s.Add(value)
+	}
+
+	checkQuantiles(t, s, values, quantiles)
+}
+
+func TestCollapse(t *testing.T) {
+	for _, sign := range []float64{1, -1} {
+		s := New()
+		var values []float64
+		// Twice as many magnitudes as there are buckets, each value in its own bucket
+		for i := 0; i < 2*maxBuckets; i++ {
+			value := sign * math.Pow(gamma, float64(i)) * 1e-3
+			values = append(values, value)
+			s.Add(value)
+		}
+
+		if buckets := len(s.positive) + len(s.negative); buckets > maxBuckets {
+			t.Errorf("sketch has %d buckets, expected at most %d", buckets, maxBuckets)
+		}
+
+		// Only the lowest values are merged, the smallest positive or the most negative ones,
+		// so the upper quantiles keep their accuracy
+		checkQuantiles(t, s, values, []float64{0.6, 0.9, 0.99, 1})
+
+		sum := 0.0
+		for _, value := range values {
+			sum += value
+		}
+		if s.Count() != uint32(len(values)) || math.Abs(s.Sum()-sum) > 1e-9*math.Abs(sum) {
+			t.Errorf("Count() = %d, Sum() = %v, expected %d and %v", s.Count(), s.Sum(), len(values), sum)
+		}
+	}
+}
+
+func TestEmptyAndInvalid(t *testing.T) {
+	s := New()
+	if s.Quantile(0.5) != 0 {
+		t.Errorf("Quantile(0.5) = %v, expected 0 for an empty sketch", s.Quantile(0.5))
+	}
+
+	s.Add(math.NaN())
+	s.Add(math.Inf(1))
+	s.Add(-5)
+	if s.Count() != 1 {
+		t.Errorf("Count() = %d, expected NaN and infinity to be ignored", s.Count())
+	}
+	if q := s.Quantile(0.5); math.Abs(q+5) > 5*relativeAccuracy {
+		t.Errorf("Quantile(0.5) = %v, expected -5", q)
+	}
+}
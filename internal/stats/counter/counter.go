@@ -0,0 +1,142 @@
+// internal/stats/counter/counter.go
+
+package counter
+
+import (
+	"strings"
+	"syscall"
+	"time"
+)
+
+// NativeBits is the width of the unsigned long counters of /proc, such as /proc/net/dev and the request
+// and sector counts of /proc/diskstats, which wrap around at 32 bits on 32-bit kernels.
+// The time fields of /proc/diskstats are unsigned int and wrap around at 32 bits on any kernel.
+var NativeBits = kernelBits()
+
+// kernelBits returns the word size of the running kernel. It differs from the one of gomon for a 32-bit build
+// on a 64-bit kernel, e.g. an armv7 userland on arm64, whose counters are still 64 bits wide.
+func kernelBits() uint {
+	var uname syscall.Utsname
+	if err := syscall.Uname(&uname); err != nil {
+		return 64
+	}
+
+	var machine strings.Builder
+	for _, c := range uname.Machine {
+		if c == 0 {
+			break
+		}
+		machine.WriteByte(byte(c))
+	}
+
+	return machineBits(machine.String())
+}
+
+// machineBits returns the word size of a kernel by its machine name as reported by uname -m,
+// e.g. x86_64, aarch64 or armv8l for a 32-bit personality on arm64
+func machineBits(machine string) uint {
+	if strings.Contains(machine, "64") || strings.HasPrefix(machine, "armv8") || machine == "s390x" {
+		return 64
+	}
+	return 32
+}
+
+// Counter turns readings of a monotonically increasing counter into increases and per-second rates
+type Counter struct {
+	bits    uint // Width the counter wraps around at, 64 for u64 counters such as the cgroup ones
+	clamp   bool // Readings below the previous one are jitter, not a wraparound or reset
+	value   uint64
+	at      time.Time
+	started bool
+}
+
+// New creates a counter that wraps around at the given number of bits
+func New(bits uint) *Counter {
+	return &Counter{bits: bits}
+}
+
+// NewClamped creates a counter that wraps around at the given number of bits and takes a reading below
+// the previous one as no increase, e.g. the idle and iowait times of /proc/stat which can go back (see proc(5))
+func NewClamped(bits uint) *Counter {
+	return &Counter{bits: bits, clamp: true}
+}
+
+// Update records a reading and returns the increase since the previous one and its rate per second.
+// It reports false for the first reading, which only serves as the base for the next one.
+// The rate is not rounded, so collectors can scale it to their unit first.
+//
+// A reading below the previous one is either a wraparound or a reset, e.g. of a restarted container.
+// It is taken as a wraparound when the previous reading was in the upper half of the counter range,
+// otherwise as a reset after which the counter started from zero. A clamped counter takes it as no increase.
+// The increase is never negative.
+func (c *Counter) Update(value uint64, at time.Time) (increase uint64, rate float32, ok bool) {
+	prev, prevAt, started := c.value, c.at, c.started
+	c.value, c.at, c.started = value, at, true
+
+	elapsed := at.Sub(prevAt).Seconds()
+	if !started || elapsed <= 0 {
+		return 0, 0, false
+	}
+
+	switch {
+	case value >= prev:
+		increase = value - prev
+	case c.clamp:
+		increase = 0
+	case c.bits < 64 && prev < 1<<c.bits && prev >= 1<<(c.bits-1):
+		increase = 1<<c.bits - prev + value
+	case c.bits >= 64 && prev >= 1<<63:
+		increase = value - prev // Unsigned arithmetic wraps around at 64 bits
+	default:
+		increase = value
+	}
+
+	return increase, float32(float64(increase) / elapsed), true
+}
+
+// Set holds counters by key, e.g. per container or per device and field,
+// and forgets the counters that were not updated between two calls of Prune
+type Set struct {
+	bits     uint
+	clamp    bool
+	counters map[string]*Counter
+	updated  map[string]bool
+}
+
+// NewSet creates an empty set of counters that wrap around at the given number of bits
+func NewSet(bits uint) *Set {
+	return &Set{
+		bits:     bits,
+		counters: make(map[string]*Counter),
+		updated:  make(map[string]bool),
+	}
+}
+
+// NewClampedSet creates an empty set of clamped counters, see NewClamped
+func NewClampedSet(bits uint) *Set {
+	set := NewSet(bits)
+	set.clamp = true
+	return set
+}
+
+// Update records a reading of the counter with the given key, see Counter.Update
+func (s *Set) Update(key string, value uint64, at time.Time) (increase uint64, rate float32, ok bool) {
+	counter, found := s.counters[key]
+	if !found {
+		counter = &Counter{bits: s.bits, clamp: s.clamp}
+		s.counters[key] = counter
+	}
+	s.updated[key] = true
+
+	return counter.Update(value, at)
+}
+
+// Prune forgets the counters that were not updated since the previous call, e.g. of removed containers
+func (s *Set) Prune() {
+	for key := range s.counters {
+		if !s.updated[key] {
+			delete(s.counters, key)
+		}
+	}
+	s.updated = make(map[string]bool)
+}
@@ -0,0 +1,87 @@
+// internal/stats/counter/counter_test.go
+
+package counter
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestUpdate(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name     string
+		counter  *Counter
+		prev     uint64
+		value    uint64
+		elapsed  time.Duration
+		increase uint64
+		rate     float32
+		ok       bool
+	}{
+		{"increase", New(64), 100, 300, 2 * time.Second, 200, 100, true},
+		{"unchanged", New(64), 100, 100, time.Second, 0, 0, true},
+		{"32-bit wraparound", New(32), math.MaxUint32 - 9, 10, time.Second, 20, 20, true},
+		{"64-bit wraparound", New(64), math.MaxUint64 - 9, 10, time.Second, 20, 20, true},
+		{"32-bit reset", New(32), 1 << 20, 10, time.Second, 10, 10, true},
+		{"64-bit reset", New(64), 1 << 40, 10, time.Second, 10, 10, true},
+		{"value above the 32-bit range", New(32), 1 << 33, 10, time.Second, 10, 10, true},
+		{"clamped decrease", NewClamped(64), 1000, 990, time.Second, 0, 0, true},
+		{"clamped increase", NewClamped(64), 990, 1000, time.Second, 10, 10, true},
+		{"no time elapsed", New(64), 100, 300, 0, 0, 0, false},
+		{"clock went back", New(64), 100, 300, -time.Second, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, ok := test.counter.Update(test.prev, start); ok {
+				t.Fatalf("Update() = true, expected false for the first reading")
+			}
+
+			increase, rate, ok := test.counter.Update(test.value, start.Add(test.elapsed))
+			if increase != test.increase || rate != test.rate || ok != test.ok {
+				t.Errorf("Update() = %d, %v, %v, expected %d, %v, %v", increase, rate, ok, test.increase, test.rate, test.ok)
+			}
+		})
+	}
+}
+
+func TestMachineBits(t *testing.T) {
+	tests := map[string]uint{
+		"x86_64":  64,
+		"aarch64": 64,
+		"armv8l":  64,
+		"ppc64le": 64,
+		"riscv64": 64,
+		"s390x":   64,
+		"armv7l":  32,
+		"i686":    32,
+		"mips":    32,
+	}
+	for machine, expected := range tests {
+		if bits := machineBits(machine); bits != expected {
+			t.Errorf("machineBits(%s) = %d, expected %d", machine, bits, expected)
+		}
+	}
+}
+
+func TestSetPrune(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	set := NewSet(64)
+
+	set.Update("sda/reads", 10, start)
+	set.Update("sdb/reads", 10, start)
+	set.Prune()
+
+	// sdb was removed, its counter is forgotten after the next prune
+	if _, _, ok := set.Update("sda/reads", 20, start.Add(time.Second)); !ok {
+		t.Errorf("Update(sda/reads) = false, expected the previous reading to be kept")
+	}
+	set.Prune()
+
+	if _, _, ok := set.Update("sdb/reads", 20, start.Add(2*time.Second)); ok {
+		t.Errorf("Update(sdb/reads) = true, expected the counter to be forgotten")
+	}
+}
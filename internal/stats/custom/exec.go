@@ -0,0 +1,125 @@
+// internal/stats/custom/exec.go
+
+package custom
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/therceman/gomon/internal/types"
+)
+
+// Stats holds the samples of one command or text file
+type Stats struct {
+	Source  string   `json:"source"` // Command entry or file name
+	Samples []Sample `json:"samples"`
+}
+
+// ExecRunner runs the commands in the background on their own interval, so slow commands never delay the read tick
+type ExecRunner struct {
+	commands []types.ExecCommand
+	timeout  time.Duration
+	interval time.Duration
+
+	mu sync.Mutex
+	// results holds the stats of the runs completed since the previous call to Results
+	results []Stats
+}
+
+// NewExecRunner creates a runner for the commands, Start begins running them
+func NewExecRunner(commands []types.ExecCommand, timeout time.Duration, interval time.Duration) *ExecRunner {
+	return &ExecRunner{commands: commands, timeout: timeout, interval: interval}
+}
+
+// Start runs the commands right away and then every interval. A run that takes longer than the interval delays the
+// next one rather than overlapping it.
+func (r *ExecRunner) Start() {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			stats := GetExecStats(r.commands, r.timeout)
+
+			r.mu.Lock()
+			r.results = append(r.results, stats...)
+			r.mu.Unlock()
+
+			<-ticker.C
+		}
+	}()
+}
+
+// Results returns the stats of the runs completed since the previous call, so each sample is only reported once
+func (r *ExecRunner) Results() []Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results := r.results
+	r.results = nil
+	return results
+}
+
+// GetExecStats runs the commands concurrently and parses their output.
+// A command that fails or exceeds the timeout is logged and skipped.
+func GetExecStats(commands []types.ExecCommand, timeout time.Duration) []Stats {
+	results := make([]*Stats, len(commands))
+	var wg sync.WaitGroup
+	for i, command := range commands {
+		wg.Add(1)
+		go func(i int, command types.ExecCommand) {
+			defer wg.Done()
+			samples, err := runCommand(command, timeout)
+			if err != nil {
+				log.Printf("Error running command %s: %v", command.Name, err)
+				return
+			}
+			results[i] = &Stats{Source: command.Name, Samples: samples}
+		}(i, command)
+	}
+	wg.Wait()
+
+	var stats []Stats
+	for _, result := range results {
+		if result != nil {
+			stats = append(stats, *result)
+		}
+	}
+	return stats
+}
+
+// runCommand runs a command through the shell and parses its stdout in the configured format
+func runCommand(command types.ExecCommand, timeout time.Duration) ([]Sample, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command.Command)
+	// Kill the whole process group on timeout, children of the shell would otherwise keep stdout open
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("timed out after %v", timeout)
+		}
+		return nil, err
+	}
+
+	switch command.Format {
+	case "influx":
+		return ParseInflux(out.String())
+	case "prometheus":
+		return ParsePrometheus(out.String())
+	default:
+		return nil, fmt.Errorf("unknown format %s", command.Format)
+	}
+}
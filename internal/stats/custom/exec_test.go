@@ -0,0 +1,36 @@
+// internal/stats/custom/exec_test.go
+
+package custom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/therceman/gomon/internal/types"
+)
+
+func TestExecRunner(t *testing.T) {
+	commands := []types.ExecCommand{
+		{Name: "queue", Format: "influx", Command: `echo "queue,name=mail depth=12i"`},
+		{Name: "slow", Format: "influx", Command: "sleep 10"},
+	}
+	runner := NewExecRunner(commands, 100*time.Millisecond, time.Hour)
+	runner.Start()
+
+	// The first run completes in the background once the slow command timed out
+	var results []Stats
+	deadline := time.Now().Add(5 * time.Second)
+	for len(results) == 0 && time.Now().Before(deadline) {
+		results = runner.Results()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(results) != 1 || results[0].Source != "queue" || len(results[0].Samples) != 1 {
+		t.Fatalf("Results() = %+v, expected the queue samples", results)
+	}
+
+	// Results are only returned once
+	if results := runner.Results(); len(results) != 0 {
+		t.Errorf("Results() = %+v, expected no results before the next run", results)
+	}
+}
@@ -0,0 +1,210 @@
+// internal/stats/custom/parse.go
+
+package custom
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Sample is a single value parsed from user supplied metrics
+type Sample struct {
+	Name  string            `json:"name"`
+	Tags  map[string]string `json:"tags"`
+	Value float64           `json:"value"`
+}
+
+// ParseInflux parses Influx line protocol. Every numeric or boolean field becomes a sample named
+// <measurement>_<field>, or just <measurement> for a field called value. String fields and timestamps are ignored.
+func ParseInflux(data string) ([]Sample, error) {
+	var samples []Sample
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// measurement[,tag=value...] field=value[,field=value...] [timestamp]
+		parts := splitUnescaped(line, ' ')
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("missing fields in line: %s", line)
+		}
+
+		series := splitUnescaped(parts[0], ',')
+		measurement := unescape(series[0])
+		tags := make(map[string]string)
+		for _, tag := range series[1:] {
+			key, value, found := cutUnescaped(tag, '=')
+			if !found {
+				return nil, fmt.Errorf("invalid tag %s in line: %s", tag, line)
+			}
+			tags[unescape(key)] = unescape(value)
+		}
+
+		for _, field := range splitUnescaped(parts[1], ',') {
+			key, rawValue, found := cutUnescaped(field, '=')
+			if !found {
+				return nil, fmt.Errorf("invalid field %s in line: %s", field, line)
+			}
+
+			value, ok := parseInfluxValue(rawValue)
+			if !ok {
+				continue
+			}
+
+			name := measurement
+			if key = unescape(key); key != "value" {
+				name += "_" + key
+			}
+			samples = append(samples, Sample{Name: name, Tags: tags, Value: value})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
+
+// parseInfluxValue parses a field value, reporting false for strings
+func parseInfluxValue(value string) (float64, bool) {
+	switch value {
+	case "t", "T", "true", "True", "TRUE":
+		return 1, true
+	case "f", "F", "false", "False", "FALSE":
+		return 0, true
+	}
+	if strings.HasPrefix(value, `"`) {
+		return 0, false
+	}
+
+	// Integers are suffixed with i, unsigned integers with u
+	value = strings.TrimRight(value, "iu")
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+		return 0, false
+	}
+	return parsed, true
+}
+
+// ParsePrometheus parses the Prometheus text exposition format.
+// Comments, timestamps and non-finite values are ignored.
+func ParsePrometheus(data string) ([]Sample, error) {
+	var samples []Sample
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// name[{label="value",...}] value [timestamp]
+		var name, rest string
+		tags := make(map[string]string)
+		if open := strings.IndexByte(line, '{'); open >= 0 && open < strings.IndexAny(line+" ", " \t") {
+			name = line[:open]
+			labels, remainder, err := parseLabels(line[open+1:])
+			if err != nil {
+				return nil, fmt.Errorf("%v in line: %s", err, line)
+			}
+			tags, rest = labels, remainder
+		} else {
+			name, rest, _ = strings.Cut(line, " ")
+		}
+
+		fields := strings.Fields(rest)
+		if name == "" || len(fields) == 0 {
+			return nil, fmt.Errorf("missing value in line: %s", line)
+		}
+
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in line: %s", line)
+		}
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+
+		samples = append(samples, Sample{Name: name, Tags: tags, Value: value})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
+
+// parseLabels parses the label set following the opening brace and returns the text after the closing brace
+func parseLabels(data string) (map[string]string, string, error) {
+	labels := make(map[string]string)
+	for {
+		data = strings.TrimLeft(data, " ,")
+		if strings.HasPrefix(data, "}") {
+			return labels, data[1:], nil
+		}
+
+		key, rest, found := strings.Cut(data, "=")
+		if !found || !strings.HasPrefix(rest, `"`) {
+			return nil, "", fmt.Errorf("invalid label")
+		}
+
+		// Label values escape backslash, double quote and line feed
+		var value strings.Builder
+		i := 1
+		for ; i < len(rest) && rest[i] != '"'; i++ {
+			if rest[i] == '\\' && i+1 < len(rest) {
+				i++
+				if rest[i] == 'n' {
+					value.WriteByte('\n')
+					continue
+				}
+			}
+			value.WriteByte(rest[i])
+		}
+		if i >= len(rest) {
+			return nil, "", fmt.Errorf("unterminated label value")
+		}
+
+		labels[strings.TrimSpace(key)] = value.String()
+		data = rest[i+1:]
+	}
+}
+
+// splitUnescaped splits line protocol on a separator that is neither escaped nor inside a quoted string
+func splitUnescaped(data string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(data); i++ {
+		switch {
+		case data[i] == '\\':
+			i++
+		case data[i] == '"':
+			quoted = !quoted
+		case data[i] == sep && !quoted:
+			parts = append(parts, data[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, data[start:])
+}
+
+// cutUnescaped cuts line protocol around the first unescaped separator
+func cutUnescaped(data string, sep byte) (string, string, bool) {
+	parts := splitUnescaped(data, sep)
+	if len(parts) < 2 {
+		return data, "", false
+	}
+	return parts[0], data[len(parts[0])+1:], true
+}
+
+// unescape removes the backslashes escaping commas, equal signs and spaces in line protocol
+func unescape(data string) string {
+	return strings.NewReplacer(`\,`, ",", `\=`, "=", `\ `, " ").Replace(data)
+}
@@ -0,0 +1,112 @@
+// internal/stats/custom/parse_test.go
+
+package custom
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseInflux(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected []Sample
+		valid    bool
+	}{
+		{
+			"fields and tags",
+			"queue,name=mail depth=12i,oldest=3.5,paused=false\n",
+			[]Sample{
+				{Name: "queue_depth", Tags: map[string]string{"name": "mail"}, Value: 12},
+				{Name: "queue_oldest", Tags: map[string]string{"name": "mail"}, Value: 3.5},
+				{Name: "queue_paused", Tags: map[string]string{"name": "mail"}, Value: 0},
+			},
+			true,
+		},
+		{
+			"value field and timestamp",
+			"licenses value=40u 1700000000000000000",
+			[]Sample{{Name: "licenses", Tags: map[string]string{}, Value: 40}},
+			true,
+		},
+		{
+			"escaped tags",
+			`disk,mount\ path=/mnt/my\ data,a\=b=c\,d used=1`,
+			[]Sample{{Name: "disk_used", Tags: map[string]string{"mount path": "/mnt/my data", "a=b": "c,d"}, Value: 1}},
+			true,
+		},
+		{
+			"string fields and comments",
+			"# queue depth\n\njob,name=backup status=\"ok, done\",runs=2i\n",
+			[]Sample{{Name: "job_runs", Tags: map[string]string{"name": "backup"}, Value: 2}},
+			true,
+		},
+		{"missing fields", "queue,name=mail", nil, false},
+		{"invalid tag", "queue,name depth=1", nil, false},
+		{"invalid field", "queue depth", nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			samples, err := ParseInflux(test.data)
+			if !test.valid {
+				if err == nil {
+					t.Errorf("ParseInflux() = %+v, expected an error", samples)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(samples, test.expected) {
+				t.Errorf("ParseInflux() = %+v, %v, expected %+v", samples, err, test.expected)
+			}
+		})
+	}
+}
+
+func TestParsePrometheus(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected []Sample
+		valid    bool
+	}{
+		{
+			"labels and timestamp",
+			"# HELP licenses_used Licenses in use\n# TYPE licenses_used gauge\nlicenses_used{product=\"ide\",tier=\"pro\"} 40 1700000000000\n",
+			[]Sample{{Name: "licenses_used", Tags: map[string]string{"product": "ide", "tier": "pro"}, Value: 40}},
+			true,
+		},
+		{
+			"no labels",
+			"backup_last_success 1.7e9\n",
+			[]Sample{{Name: "backup_last_success", Tags: map[string]string{}, Value: 1.7e9}},
+			true,
+		},
+		{
+			"quoted label values",
+			`job_info{path="C:\\jobs",msg="say \"hi\"\nbye",list="a,b} c"} 1`,
+			[]Sample{{Name: "job_info", Tags: map[string]string{"path": `C:\jobs`, "msg": "say \"hi\"\nbye", "list": "a,b} c"}, Value: 1}},
+			true,
+		},
+		{"non-finite values", "a NaN\nb +Inf\nc{x=\"y\"} -Inf\n", nil, true},
+		{"missing value", "queue_depth\n", nil, false},
+		{"invalid value", "queue_depth twelve\n", nil, false},
+		{"unquoted label", "queue_depth{name=mail} 12\n", nil, false},
+		{"unterminated label", `queue_depth{name="mail} 12`, nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			samples, err := ParsePrometheus(test.data)
+			if !test.valid {
+				if err == nil {
+					t.Errorf("ParsePrometheus() = %+v, expected an error", samples)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(samples, test.expected) {
+				t.Errorf("ParsePrometheus() = %+v, %v, expected %+v", samples, err, test.expected)
+			}
+		})
+	}
+}
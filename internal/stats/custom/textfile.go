@@ -0,0 +1,43 @@
+// internal/stats/custom/textfile.go
+
+package custom
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GetTextfileStats parses the *.prom files in dir, which hold metrics in the Prometheus text format.
+// Files should be written atomically, e.g. to a temporary file that is renamed, to avoid reading partial output.
+func GetTextfileStats(dir string) ([]Stats, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.prom"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var stats []Stats
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Error reading text file %s: %v", path, err)
+			continue
+		}
+
+		samples, err := ParsePrometheus(string(data))
+		if err != nil {
+			log.Printf("Error parsing text file %s: %v", path, err)
+			continue
+		}
+
+		stats = append(stats, Stats{
+			Source:  strings.TrimSuffix(filepath.Base(path), ".prom"),
+			Samples: samples,
+		})
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,33 @@
+// internal/stats/custom/textfile_test.go
+
+package custom
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/therceman/gomon/internal/hostfs/hostfstest"
+)
+
+func TestGetTextfileStats(t *testing.T) {
+	dir := t.TempDir()
+	hostfstest.WriteFiles(t, dir, map[string]string{
+		"backup.prom": "# TYPE backup_last_success gauge\nbackup_last_success{job=\"db\"} 1700000000\n",
+		"broken.prom": "backup_last_success{job=db} 1\n",
+		"notes.txt":   "not a metric\n",
+	})
+
+	stats, err := GetTextfileStats(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files that cannot be parsed are skipped, others than *.prom are not read
+	expected := []Stats{{
+		Source:  "backup",
+		Samples: []Sample{{Name: "backup_last_success", Tags: map[string]string{"job": "db"}, Value: 1700000000}},
+	}}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("GetTextfileStats() = %+v, expected %+v", stats, expected)
+	}
+}
@@ -0,0 +1,160 @@
+// internal/stats/diskio/stats.go
+
+package diskio
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/hostfs"
+	"github.com/therceman/gomon/internal/stats/counter"
+)
+
+// sectorSize is the unit of the sector counters in /proc/diskstats, independent of the device
+const sectorSize = 512
+
+// Stats holds the I/O activity of a block device between two read ticks
+type Stats struct {
+	Device           string  `json:"device"`              // Block device name, e.g. sda
+	ReadBytesPerSec  float32 `json:"read_bytes_per_sec"`  // Bytes read per second
+	WriteBytesPerSec float32 `json:"write_bytes_per_sec"` // Bytes written per second
+	ReadIOPS         float32 `json:"read_iops"`           // Completed reads per second
+	WriteIOPS        float32 `json:"write_iops"`          // Completed writes per second
+	AwaitMs          float32 `json:"await_ms"`            // Average time per completed request in ms
+	UtilPerc         float32 `json:"util_perc"`           // Share of time the device was busy
+}
+
+// deviceSample holds the /proc/diskstats counters of a device kept between read ticks
+type deviceSample struct {
+	reads        uint64
+	readSectors  uint64
+	readMs       uint64
+	writes       uint64
+	writeSectors uint64
+	writeMs      uint64
+	ioMs         uint64
+}
+
+// counters holds the request and sector counters of /proc/diskstats per device and field
+var counters = counter.NewSet(counter.NativeBits)
+
+// msCounters holds the time counters of /proc/diskstats per device and field, which are unsigned int
+var msCounters = counter.NewSet(32)
+
+// GetStats retrieves I/O rates of the block devices matching include and not matching exclude,
+// either pattern may be nil. There are no stats on the first call, it only records the counters.
+func GetStats(include *regexp.Regexp, exclude *regexp.Regexp) ([]Stats, error) {
+	samples, err := getSamples(include, exclude)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	var stats []Stats
+	for device, sample := range samples {
+		// Devices that just appeared have no previous counters yet
+		ready := true
+		update := func(set *counter.Set, field string, value uint64) (uint64, float32) {
+			increase, rate, ok := set.Update(device+"/"+field, value, now)
+			ready = ready && ok
+			return increase, rate
+		}
+
+		reads, readsPerSec := update(counters, "reads", sample.reads)
+		writes, writesPerSec := update(counters, "writes", sample.writes)
+		_, readSectorsPerSec := update(counters, "read_sectors", sample.readSectors)
+		_, writeSectorsPerSec := update(counters, "write_sectors", sample.writeSectors)
+		readMs, _ := update(msCounters, "read_ms", sample.readMs)
+		writeMs, _ := update(msCounters, "write_ms", sample.writeMs)
+		_, ioMsPerSec := update(msCounters, "io_ms", sample.ioMs)
+		if !ready {
+			continue
+		}
+
+		var awaitMs float32
+		if reads+writes > 0 {
+			awaitMs = float32(readMs+writeMs) / float32(reads+writes)
+		}
+
+		// Milliseconds busy per second, as a share of the second
+		utilPerc := ioMsPerSec / 10
+		if utilPerc > 100 {
+			utilPerc = 100
+		}
+
+		stats = append(stats, Stats{
+			Device:           device,
+			ReadBytesPerSec:  helpers.RoundToTwoDecimal(readSectorsPerSec * sectorSize),
+			WriteBytesPerSec: helpers.RoundToTwoDecimal(writeSectorsPerSec * sectorSize),
+			ReadIOPS:         helpers.RoundToTwoDecimal(readsPerSec),
+			WriteIOPS:        helpers.RoundToTwoDecimal(writesPerSec),
+			AwaitMs:          helpers.RoundToTwoDecimal(awaitMs),
+			UtilPerc:         helpers.RoundToTwoDecimal(utilPerc),
+		})
+	}
+	counters.Prune()
+	msCounters.Prune()
+
+	return stats, nil
+}
+
+// getSamples reads the counters of the selected devices from /proc/diskstats
+func getSamples(include *regexp.Regexp, exclude *regexp.Regexp) (samples map[string]deviceSample, err error) {
+	path := hostfs.Proc("diskstats")
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	samples = make(map[string]deviceSample)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 14 {
+			continue
+		}
+
+		device := fields[2]
+		if include != nil && !include.MatchString(device) {
+			continue
+		}
+		if exclude != nil && exclude.MatchString(device) {
+			continue
+		}
+
+		var values [11]uint64
+		for i := range values {
+			values[i], err = strconv.ParseUint(fields[i+3], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		samples[device] = deviceSample{
+			reads:        values[0],
+			readSectors:  values[2],
+			readMs:       values[3],
+			writes:       values[4],
+			writeSectors: values[6],
+			writeMs:      values[7],
+			ioMs:         values[9],
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	return samples, nil
+}
@@ -0,0 +1,75 @@
+// internal/stats/docker/cgroup.go
+
+package docker
+
+import (
+	"log"
+
+	"github.com/therceman/gomon/internal/cgroup"
+)
+
+// cgroupStats holds the container metrics read from its cgroup
+type cgroupStats struct {
+	CPUReady bool               `json:"-"`
+	CPU      float32            `json:"cpu"`
+	PSI      map[string]float32 `json:"psi"`
+	PIDsMax  uint64             `json:"pids_max"`
+	Memory   map[string]float32 `json:"memory"`
+	CPUQuota map[string]float32 `json:"cpu_quota"`
+	// Increases of counters since the previous read, e.g. container_oom_kills
+	Increases map[string]float32 `json:"increases"`
+}
+
+// getCgroupStats reads the container metrics from its cgroup
+func getCgroupStats(containerID string) (cgroupStats, error) {
+	group, err := cgroup.FindContainer(containerID)
+	if err != nil {
+		return cgroupStats{}, err
+	}
+
+	cpuReady, cpu, err := getCPUStats(containerID, group)
+	if err != nil {
+		return cgroupStats{}, err
+	}
+
+	// CFS bandwidth control may be disabled in the kernel
+	cpuQuota, throttledIncreases, err := getThrottlingStats(containerID, group, cpuReady, cpu)
+	if err != nil {
+		log.Printf("Error reading CPU throttling stats of container %s: %v", containerID, err)
+	}
+
+	// Pressure is optional, the kernel may be built without PSI
+	pressure, err := getPressureStats(containerID, group)
+	if err != nil {
+		log.Printf("Error reading pressure stats of container %s: %v", containerID, err)
+	}
+
+	// The pids controller may not be enabled for the container
+	pidsMax, err := cgroup.ReadPIDsMax(group)
+	if err != nil {
+		pidsMax = 0
+	}
+
+	// The memory breakdown is best effort, docker stats still reports the usage
+	memory, memoryEvents, err := getMemoryStats(containerID, group)
+	if err != nil {
+		log.Printf("Error reading memory stats of container %s: %v", containerID, err)
+	}
+
+	increases := make(map[string]float32)
+	for _, counts := range []map[string]float32{throttledIncreases, memoryEvents} {
+		for name, increase := range counts {
+			increases[name] = increase
+		}
+	}
+
+	return cgroupStats{
+		CPUReady:  cpuReady,
+		CPU:       cpu,
+		PSI:       pressure,
+		PIDsMax:   pidsMax,
+		Memory:    memory,
+		CPUQuota:  cpuQuota,
+		Increases: increases,
+	}, nil
+}
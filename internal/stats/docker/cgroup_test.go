@@ -0,0 +1,52 @@
+// internal/stats/docker/cgroup_test.go
+
+package docker
+
+import (
+	"testing"
+
+	"github.com/therceman/gomon/internal/hostfs/hostfstest"
+)
+
+// TestMetricNames checks that the metrics read from a container cgroup with a memory and CPU limit are all listed
+func TestMetricNames(t *testing.T) {
+	containerID := "0123456789ab"
+	group := "fs/cgroup/system.slice/docker-" + containerID + "cdef.scope/"
+	hostfstest.FakeSys(t, map[string]string{
+		"fs/cgroup/cgroup.controllers": "cpu io memory pids\n",
+		group + "cpu.stat":             "usage_usec 1000\nnr_periods 10\nnr_throttled 2\nthrottled_usec 700\n",
+		group + "cpu.max":              "100000 100000\n",
+		group + "memory.current":       "4096000\n",
+		group + "memory.stat":          "anon 1000\nfile 2000\nkernel 150\nshmem 60\ninactive_file 70\n",
+		group + "memory.max":           "8192000\n",
+		group + "memory.swap.current":  "0\n",
+		group + "memory.events":        "low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n",
+		group + "pids.max":             "max\n",
+	})
+
+	// Rates and increases need a previous reading
+	var stats cgroupStats
+	for i := 0; i < 2; i++ {
+		var err error
+		if stats, err = getCgroupStats(containerID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, name := range MetricNames() {
+		names[name] = true
+	}
+	reported := 0
+	for _, metrics := range []map[string]float32{stats.Memory, stats.CPUQuota, stats.Increases} {
+		for name := range metrics {
+			reported++
+			if !names[name] {
+				t.Errorf("metric %s is reported but not in MetricNames()", name)
+			}
+		}
+	}
+	if reported != len(names) {
+		t.Errorf("%d metrics were reported, expected all %d of MetricNames()", reported, len(names))
+	}
+}
@@ -0,0 +1,30 @@
+// internal/stats/docker/cpu.go
+
+package docker
+
+import (
+	"time"
+
+	"github.com/therceman/gomon/internal/cgroup"
+	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/stats/counter"
+)
+
+// cpuCounters holds the cgroup CPU usage in microseconds per container ID
+var cpuCounters = counter.NewSet(64)
+
+// getCPUStats calculates the CPU usage percentage of the container since the previous call.
+// Like docker stats, 100% corresponds to one fully used core.
+func getCPUStats(containerID string, group string) (bool, float32, error) {
+	usage, err := cgroup.ReadCPUUsage(group)
+	if err != nil {
+		return false, 0, err
+	}
+
+	_, usecPerSec, ok := cpuCounters.Update(containerID, usage, time.Now())
+	if !ok {
+		return false, 0, nil
+	}
+
+	return true, helpers.RoundToTwoDecimal(usecPerSec / 1e6 * 100), nil
+}
@@ -0,0 +1,165 @@
+// internal/stats/docker/logs.go
+
+package docker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/therceman/gomon/internal/hostfs"
+	"github.com/therceman/gomon/internal/types"
+)
+
+// maxLogBytesPerTick bounds the log data read per container and read tick, so a log burst cannot stall the collectors
+const maxLogBytesPerTick = 8 * 1024 * 1024
+
+// logReader is reused for every log, lines longer than its buffer are skipped
+var logReader = bufio.NewReaderSize(nil, 64*1024)
+
+// logFollower remembers how far the json-file log of a container has been read
+type logFollower struct {
+	path   string
+	inode  uint64
+	offset int64
+}
+
+// logFollowers holds the log position per container ID
+var logFollowers = make(map[string]*logFollower)
+
+// logEntry is a line of the json-file logging driver
+type logEntry struct {
+	Log string `json:"log"`
+}
+
+// getLogMatches counts the log lines written by the container since the previous call that match each pattern
+// configured for it. Only the json-file logging driver is supported. The log is followed from its end on the first
+// call, and lines written to a rotated file before it was noticed are still counted.
+func getLogMatches(containerID string, name string, patterns []types.LogPattern) (map[string]uint32, error) {
+	var selected []types.LogPattern
+	for _, pattern := range patterns {
+		if pattern.Container == "*" || pattern.Container == name || pattern.Container == containerID {
+			selected = append(selected, pattern)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, nil
+	}
+
+	matches, err := filepath.Glob(hostfs.Root("var", "lib", "docker", "containers", containerID+"*", "*-json.log"))
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) != 1 {
+		return nil, fmt.Errorf("json-file log not found for container %s", containerID)
+	}
+	path := matches[0]
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	inode := info.Sys().(*syscall.Stat_t).Ino
+
+	counts := make(map[string]uint32, len(selected))
+	for _, pattern := range selected {
+		counts[pattern.Name] = 0
+	}
+
+	follower, found := logFollowers[containerID]
+	if !found || follower.path != path {
+		logFollowers[containerID] = &logFollower{path: path, inode: inode, offset: info.Size()}
+		return counts, nil
+	}
+
+	if follower.inode != inode {
+		// Docker renames the full log to <log>.1 on rotation, count what was written there since the last read
+		if rotated, err := os.Stat(path + ".1"); err == nil && rotated.Sys().(*syscall.Stat_t).Ino == follower.inode {
+			if _, err := countLogMatches(path+".1", follower.offset, selected, counts); err != nil {
+				return nil, err
+			}
+		}
+		follower.inode = inode
+		follower.offset = 0
+	}
+
+	// A log that shrank was truncated, e.g. by truncate -s 0
+	if info.Size() < follower.offset {
+		follower.offset = 0
+	}
+
+	offset, err := countLogMatches(path, follower.offset, selected, counts)
+	if err != nil {
+		return nil, err
+	}
+	follower.offset = offset
+
+	return counts, nil
+}
+
+// countLogMatches adds the matches of the complete lines after offset to counts and returns the offset after them
+func countLogMatches(path string, offset int64, patterns []types.LogPattern, counts map[string]uint32) (newOffset int64, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return offset, err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return offset, err
+	}
+	limit := min(info.Size()-offset, maxLogBytesPerTick)
+	if limit <= 0 {
+		return offset, nil
+	}
+	logReader.Reset(io.NewSectionReader(file, offset, limit))
+
+	// complete is the length of the complete lines read, a partially written last line is read again on the next call
+	var complete, read int64
+	var skipping bool
+	for {
+		line, err := logReader.ReadSlice('\n')
+		read += int64(len(line))
+		if err == bufio.ErrBufferFull {
+			skipping = true
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return offset, err
+		}
+		complete = read
+		if skipping {
+			skipping = false
+			continue
+		}
+
+		var entry logEntry
+		text := string(line[:len(line)-1])
+		if err := json.Unmarshal(line, &entry); err == nil {
+			text = entry.Log
+		}
+
+		for _, pattern := range patterns {
+			if pattern.Pattern.MatchString(text) {
+				counts[pattern.Name]++
+			}
+		}
+	}
+
+	if complete == 0 && limit == maxLogBytesPerTick {
+		return offset + limit, nil // Skip a line longer than the read limit
+	}
+	return offset + complete, nil
+}
@@ -0,0 +1,54 @@
+// internal/stats/docker/logs_test.go
+
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/therceman/gomon/internal/types"
+)
+
+func TestCountLogMatches(t *testing.T) {
+	patterns := []types.LogPattern{
+		{Container: "*", Name: "errors", Pattern: regexp.MustCompile(`ERROR`)},
+		{Container: "*", Name: "timeouts", Pattern: regexp.MustCompile(`timeout`)},
+	}
+
+	// A line longer than the reader buffer is skipped, the partially written last line is left for the next call
+	longLine := `{"log":"ERROR ` + strings.Repeat("x", 100*1024) + `\n","stream":"stderr"}` + "\n"
+	complete := `{"log":"ERROR timeout\n","stream":"stderr"}` + "\n" +
+		`{"log":"ok\n","stream":"stdout"}` + "\n" +
+		longLine +
+		"ERROR not json\n"
+	partial := `{"log":"ERROR partial`
+
+	path := filepath.Join(t.TempDir(), "container-json.log")
+	if err := os.WriteFile(path, []byte(complete+partial), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]uint32{"errors": 0, "timeouts": 0}
+	offset, err := countLogMatches(path, 0, patterns, counts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]uint32{"errors": 2, "timeouts": 1}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("countLogMatches() counts = %v, expected %v", counts, expected)
+	}
+	if offset != int64(len(complete)) {
+		t.Errorf("countLogMatches() = %d, expected %d", offset, len(complete))
+	}
+
+	// Nothing was written since
+	offset, err = countLogMatches(path, int64(len(complete+partial)), patterns, counts)
+	if err != nil || offset != int64(len(complete+partial)) {
+		t.Errorf("countLogMatches() = %d, %v, expected %d", offset, err, len(complete+partial))
+	}
+}
@@ -0,0 +1,74 @@
+// internal/stats/docker/memory.go
+
+package docker
+
+import (
+	"time"
+
+	"github.com/therceman/gomon/internal/cgroup"
+	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/stats/counter"
+)
+
+// memoryMetricNames are the metrics reported by getMemoryStats, its increases included
+var memoryMetricNames = []string{
+	"container_mem_anon_mb", "container_mem_file_mb", "container_mem_kernel_mb", "container_mem_shmem_mb",
+	"container_mem_swap_mb", "container_mem_working_set_mb", "container_mem_limit_mb", "container_mem_working_set_perc",
+	"container_mem_limit_hits", "container_oom_events", "container_oom_kills",
+}
+
+// memoryEventCounters holds the memory event counters per container ID and event
+var memoryEventCounters = counter.NewSet(64)
+
+// getMemoryStats reads the memory breakdown of the container cgroup and the memory events since the previous call.
+// Unlike the docker stats usage, the working set excludes page cache the kernel can reclaim at no cost.
+func getMemoryStats(containerID string, group string) (metrics map[string]float32, events map[string]float32, err error) {
+	stats, err := cgroup.ReadMemoryStats(group)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	workingSet := uint64(0)
+	if stats.Usage > stats.InactiveFile {
+		workingSet = stats.Usage - stats.InactiveFile
+	}
+
+	metrics = map[string]float32{
+		"container_mem_anon_mb":        bytesToMB(stats.Anon),
+		"container_mem_file_mb":        bytesToMB(stats.File),
+		"container_mem_kernel_mb":      bytesToMB(stats.Kernel),
+		"container_mem_shmem_mb":       bytesToMB(stats.Shmem),
+		"container_mem_swap_mb":        bytesToMB(stats.Swap),
+		"container_mem_working_set_mb": bytesToMB(workingSet),
+	}
+	if stats.Limit > 0 {
+		metrics["container_mem_limit_mb"] = bytesToMB(stats.Limit)
+		metrics["container_mem_working_set_perc"] = helpers.RoundToTwoDecimal(float32(workingSet) / float32(stats.Limit) * 100)
+	}
+
+	// Event counters are optional, the files differ between kernel versions
+	counts, err := cgroup.ReadMemoryEvents(group)
+	if err != nil {
+		return metrics, nil, nil
+	}
+
+	// Events are reported per read tick
+	now := time.Now()
+	events = make(map[string]float32)
+	for name, value := range map[string]uint64{
+		"container_mem_limit_hits": counts.Max,
+		"container_oom_events":     counts.OOM,
+		"container_oom_kills":      counts.OOMKill,
+	} {
+		if increase, _, ok := memoryEventCounters.Update(containerID+"/"+name, value, now); ok {
+			events[name] = float32(increase)
+		}
+	}
+
+	return metrics, events, nil
+}
+
+// bytesToMB converts bytes to megabytes rounded to two decimals
+func bytesToMB(bytes uint64) float32 {
+	return helpers.RoundToTwoDecimal(float32(bytes) / 1024 / 1024)
+}
@@ -0,0 +1,63 @@
+// internal/stats/docker/pid.go
+
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/therceman/gomon/internal/helpers"
+)
+
+// shortIDLength is the length of the container IDs printed by docker stats and docker ps
+const shortIDLength = 12
+
+// GetInitPIDs returns the host PID of the init process of each running container, keyed by short container ID
+func GetInitPIDs() (map[string]uint32, error) {
+	psCmd := exec.Command("docker", "ps", "--quiet")
+	psOutput, err := psCmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error executing docker ps --quiet command: %v", err)
+	}
+
+	containerIDs := strings.Fields(string(psOutput))
+	if len(containerIDs) == 0 {
+		return nil, nil
+	}
+
+	args := append([]string{"inspect", "--format", "{{.Id}} {{.State.Pid}}"}, containerIDs...)
+	cmd := exec.Command("docker", args...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error executing docker inspect command: %v", err)
+	}
+
+	pids := make(map[string]uint32)
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || len(fields[0]) < shortIDLength {
+			continue
+		}
+
+		pid, err := helpers.ConvertStringToUint32(fields[1])
+		if err != nil {
+			return nil, err
+		}
+
+		// Containers that stopped in the meantime report PID 0
+		if pid != 0 {
+			pids[fields[0][:shortIDLength]] = pid
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return pids, nil
+}
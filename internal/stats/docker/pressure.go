@@ -0,0 +1,28 @@
+// internal/stats/docker/pressure.go
+
+package docker
+
+import (
+	"github.com/therceman/gomon/internal/cgroup"
+	"github.com/therceman/gomon/internal/psi"
+	"github.com/therceman/gomon/internal/stats/counter"
+)
+
+// pressureCounters holds the stall totals per container ID, which are u64 in the kernel
+var pressureCounters = counter.NewSet(64)
+
+// getPressureStats reads the cpu, memory and io pressure of the container cgroup.
+// Pressure files are only available on cgroup v2.
+func getPressureStats(containerID string, group string) (map[string]float32, error) {
+	if !cgroup.IsV2() {
+		return nil, nil
+	}
+
+	files := map[string]string{
+		"cpu":    cgroup.Path("cpu", group, "cpu.pressure"),
+		"memory": cgroup.Path("memory", group, "memory.pressure"),
+		"io":     cgroup.Path("io", group, "io.pressure"),
+	}
+
+	return psi.Collect(files, pressureCounters, containerID)
+}
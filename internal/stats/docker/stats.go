@@ -11,23 +11,44 @@ import (
 	"strings"
 
 	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/stats/process"
+	"github.com/therceman/gomon/internal/types"
 )
 
 type Stats struct {
-	ID      string  `json:"id"`
-	Name    string  `json:"name"`
-	CPU     float32 `json:"cpu"`
-	MemMB   float32 `json:"mem"`
-	MemPerc float32 `json:"mem_perc"`
-	NetI    float32 `json:"net_i"`
-	NetO    float32 `json:"net_o"`
-	BlockI  float32 `json:"block_i"`
-	BlockO  float32 `json:"block_o"`
-	PIDs    int     `json:"pids"`
-	SizeMB  float32 `json:"size"`
+	ID       string             `json:"id"`
+	Name     string             `json:"name"`
+	CPUReady bool               `json:"-"` // False until a previous CPU sample is available
+	CPU      float32            `json:"cpu"`
+	MemMB    float32            `json:"mem"`
+	MemPerc  float32            `json:"mem_perc"`
+	NetI     float32            `json:"net_i"`
+	NetO     float32            `json:"net_o"`
+	BlockI   float32            `json:"block_i"`
+	BlockO   float32            `json:"block_o"`
+	PIDs     int                `json:"pids"`
+	PIDsMax  uint64             `json:"pids_max"` // Task limit of the container, 0 when unlimited
+	FDReady  bool               `json:"-"`        // False when the file descriptors of the init process were not readable
+	OpenFDs  uint32             `json:"open_fds"` // Open file descriptors of the init process
+	FDLimit  uint64             `json:"fd_limit"` // Soft open files limit of the init process, 0 when unknown
+	SizeMB   float32            `json:"size"`
+	PSI      map[string]float32 `json:"psi"`       // Pressure stall metrics, e.g. psi_cpu_some_avg10_perc
+	Memory   map[string]float32 `json:"memory"`    // Memory breakdown, e.g. container_mem_working_set_mb
+	CPUQuota map[string]float32 `json:"cpu_quota"` // CPU quota usage and throttling, e.g. container_cpu_quota_perc
+	Logs     map[string]uint32  `json:"logs"`      // Log lines matching each configured counter since the previous read
+	// Increases of counters since the previous read, e.g. container_oom_kills and container_cpu_throttled_ms
+	Increases map[string]float32 `json:"increases"`
 }
 
-func GetStats() ([]Stats, error) {
+// MetricNames returns the names of the metrics read from the cgroup of the containers, reported in the Memory,
+// CPUQuota and Increases maps
+func MetricNames() []string {
+	return append(append([]string(nil), memoryMetricNames...), throttlingMetricNames...)
+}
+
+// GetStats retrieves the stats of the running containers, initPIDs are the host PIDs of their init processes
+// as returned by GetInitPIDs
+func GetStats(logPatterns []types.LogPattern, initPIDs map[string]uint32) ([]Stats, error) {
 	cmd := exec.Command("docker", "stats", "--no-stream")
 	var out bytes.Buffer
 	cmd.Stdout = &out
@@ -41,6 +62,29 @@ func GetStats() ([]Stats, error) {
 		return nil, err
 	}
 
+	pruneSamples(stats)
+
+	// File descriptors are only tracked for the init process, which usually is the main service
+	for i := range stats {
+		if pid, found := initPIDs[stats[i].ID]; found {
+			openFDs, fdLimit, err := process.ReadFDUsage(helpers.ConvertUint32ToString(pid))
+			if err != nil {
+				continue // gomon needs root or CAP_SYS_PTRACE to read them
+			}
+			stats[i].FDReady, stats[i].OpenFDs, stats[i].FDLimit = true, openFDs, fdLimit
+		}
+	}
+
+	// Log counters are optional, containers may use another logging driver
+	for i := range stats {
+		logs, err := getLogMatches(stats[i].ID, stats[i].Name, logPatterns)
+		if err != nil {
+			log.Printf("Error reading logs of container %s: %v", stats[i].Name, err)
+			continue
+		}
+		stats[i].Logs = logs
+	}
+
 	// Ensure we do not hold on to memory longer than needed
 	out.Reset()
 	return stats, nil
@@ -91,6 +135,12 @@ func parseDockerStatsOutput(output string) ([]Stats, error) {
 			continue
 		}
 
+		// docker stats only measures a short slice, prefer the cgroup usage over the whole read interval
+		containerStats, err := getCgroupStats(fields[0])
+		if err != nil {
+			containerStats = cgroupStats{CPUReady: true, CPU: cpuUsage} // cgroup is not readable, keep the docker stats value
+		}
+
 		memUsage, err := helpers.ConvertMemoryToMB(fields[3])
 		if err != nil {
 			log.Printf("Error parsing memory usage: %v", err)
@@ -116,19 +166,47 @@ func parseDockerStatsOutput(output string) ([]Stats, error) {
 		}
 
 		stat := Stats{
-			ID:      fields[0],
-			Name:    fields[1],
-			CPU:     helpers.RoundToTwoDecimal(cpuUsage),
-			MemMB:   helpers.RoundToTwoDecimal(memUsage),
-			MemPerc: helpers.RoundToTwoDecimal(memPerc),
-			NetI:    helpers.RoundToTwoDecimal(netI),
-			NetO:    helpers.RoundToTwoDecimal(netO),
-			BlockI:  helpers.RoundToTwoDecimal(blockI),
-			BlockO:  helpers.RoundToTwoDecimal(blockO),
-			PIDs:    pids,
-			SizeMB:  helpers.RoundToTwoDecimal(containerSize),
+			ID:       fields[0],
+			Name:     fields[1],
+			CPUReady: containerStats.CPUReady,
+			CPU:      helpers.RoundToTwoDecimal(containerStats.CPU),
+			PSI:      containerStats.PSI,
+			Memory:   containerStats.Memory,
+			CPUQuota: containerStats.CPUQuota,
+			MemMB:    helpers.RoundToTwoDecimal(memUsage),
+			MemPerc:  helpers.RoundToTwoDecimal(memPerc),
+			NetI:     helpers.RoundToTwoDecimal(netI),
+			NetO:     helpers.RoundToTwoDecimal(netO),
+			BlockI:   helpers.RoundToTwoDecimal(blockI),
+			BlockO:   helpers.RoundToTwoDecimal(blockO),
+			PIDs:     pids,
+			PIDsMax:  containerStats.PIDsMax,
+			SizeMB:   helpers.RoundToTwoDecimal(containerSize),
+
+			// Counter increases are aggregated as such by the fetch
+			Increases: containerStats.Increases,
 		}
 		stats = append(stats, stat)
 	}
 	return stats, nil
 }
+
+// pruneSamples forgets the samples of containers that are no longer running.
+// Counters are forgotten when they were not updated on the previous read.
+func pruneSamples(stats []Stats) {
+	cpuCounters.Prune()
+	memoryEventCounters.Prune()
+	throttlingCounters.Prune()
+	pressureCounters.Prune()
+
+	running := make(map[string]bool, len(stats))
+	for _, stat := range stats {
+		running[stat.ID] = true
+	}
+
+	for containerID := range logFollowers {
+		if !running[containerID] {
+			delete(logFollowers, containerID)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// internal/stats/docker/throttling.go
+
+package docker
+
+import (
+	"time"
+
+	"github.com/therceman/gomon/internal/cgroup"
+	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/stats/counter"
+)
+
+// throttlingMetricNames are the metrics reported by getThrottlingStats, its increases included
+var throttlingMetricNames = []string{
+	"container_cpu_quota_cores", "container_cpu_quota_perc", "container_cpu_throttled_periods_perc",
+	"container_cpu_throttled_ms",
+}
+
+// throttlingCounters holds the CFS bandwidth counters per container ID and field
+var throttlingCounters = counter.NewSet(64)
+
+// getThrottlingStats calculates how much the container was throttled since the previous call
+// and its CPU usage relative to its quota rather than to a single core.
+// Containers without a CPU limit are never throttled and only get the counters.
+// The throttled time is returned separately as an increase since the previous call.
+func getThrottlingStats(containerID string, group string, cpuReady bool, cpuPerc float32) (metrics map[string]float32, increases map[string]float32, err error) {
+	throttling, err := cgroup.ReadCPUThrottling(group)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	quota, err := cgroup.ReadCPUQuota(group)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metrics = make(map[string]float32)
+	if quota > 0 {
+		metrics["container_cpu_quota_cores"] = helpers.RoundToTwoDecimal(float32(quota))
+		if cpuReady {
+			metrics["container_cpu_quota_perc"] = helpers.RoundToTwoDecimal(cpuPerc / float32(quota))
+		}
+	}
+
+	now := time.Now()
+	periods, _, periodsOK := throttlingCounters.Update(containerID+"/periods", throttling.Periods, now)
+	throttled, _, throttledOK := throttlingCounters.Update(containerID+"/throttled", throttling.Throttled, now)
+	throttledUsec, _, throttledUsecOK := throttlingCounters.Update(containerID+"/throttled_usec", throttling.ThrottledUsec, now)
+	if !periodsOK || !throttledOK || !throttledUsecOK {
+		return metrics, nil, nil
+	}
+
+	// Keep the share within 100% when the counters were reset between two reads
+	if throttled > periods {
+		throttled = periods
+	}
+
+	metrics["container_cpu_throttled_periods_perc"] = 0
+	if periods > 0 {
+		metrics["container_cpu_throttled_periods_perc"] = helpers.RoundToTwoDecimal(float32(throttled) / float32(periods) * 100)
+	}
+	increases = map[string]float32{
+		"container_cpu_throttled_ms": helpers.RoundToTwoDecimal(float32(throttledUsec) / 1000),
+	}
+
+	return metrics, increases, nil
+}
@@ -0,0 +1,200 @@
+// internal/stats/filesystem/stats.go
+
+package filesystem
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/hostfs"
+)
+
+// Stats holds the usage of a single mounted filesystem
+type Stats struct {
+	Mount          string  `json:"mount"`            // Mount point
+	Device         string  `json:"device"`           // Mounted device, e.g. /dev/sda1
+	FSType         string  `json:"fs_type"`          // Filesystem type, e.g. ext4
+	TotalBytes     float32 `json:"total_bytes"`      // Size of the filesystem
+	UsedBytes      float32 `json:"used_bytes"`       // Used space, including space reserved for root
+	AvailBytes     float32 `json:"avail_bytes"`      // Space available to unprivileged users
+	UsedPerc       float32 `json:"used_perc"`        // Used percentage as reported by df
+	InodesTotal    float32 `json:"inodes_total"`     // Number of inodes
+	InodesUsed     float32 `json:"inodes_used"`      // Number of used inodes
+	InodesUsedPerc float32 `json:"inodes_used_perc"` // Used inodes percentage
+}
+
+// mountInfo describes a mount point listed in /proc/self/mountinfo
+type mountInfo struct {
+	Mount  string
+	Device string
+	FSType string
+}
+
+// pseudoFSTypes are skipped when discovering mount points, they do not hold persistent data
+var pseudoFSTypes = map[string]bool{
+	"autofs": true, "binfmt_misc": true, "bpf": true, "cgroup": true, "cgroup2": true,
+	"configfs": true, "debugfs": true, "devpts": true, "devtmpfs": true, "efivarfs": true,
+	"fuse.lxcfs": true, "fusectl": true, "hugetlbfs": true, "mqueue": true, "nsfs": true,
+	"overlay": true, "proc": true, "pstore": true, "ramfs": true, "rpc_pipefs": true,
+	"securityfs": true, "selinuxfs": true, "squashfs": true, "sysfs": true, "tmpfs": true,
+	"tracefs": true,
+}
+
+// GetStats retrieves the usage of the given mount points.
+// When no mount points are given they are discovered from the mountinfo of the host.
+func GetStats(mounts []string) ([]Stats, error) {
+	mountInfos, err := getMountInfos()
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []mountInfo
+	if len(mounts) == 0 {
+		targets = discoverMounts(mountInfos)
+	} else {
+		for _, mount := range mounts {
+			target := mountInfo{Mount: mount}
+			// Later entries shadow earlier ones mounted at the same path
+			for _, info := range mountInfos {
+				if info.Mount == mount {
+					target = info
+				}
+			}
+			targets = append(targets, target)
+		}
+	}
+
+	var stats []Stats
+	for _, target := range targets {
+		stat, err := getMountStats(target)
+		if err != nil {
+			log.Printf("Skipping mount point: %v", err)
+			continue
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, nil
+}
+
+// discoverMounts returns the mount points of real filesystems, each device is only reported once
+func discoverMounts(mountInfos []mountInfo) []mountInfo {
+	var mounts []mountInfo
+	seen := make(map[string]bool)
+	for _, info := range mountInfos {
+		if pseudoFSTypes[info.FSType] || seen[info.Device] {
+			continue
+		}
+		seen[info.Device] = true
+		mounts = append(mounts, info)
+	}
+	return mounts
+}
+
+// getMountInfos parses the mount points of the host init process,
+// as gomon may run in a mount namespace of its own
+func getMountInfos() (mountInfos []mountInfo, err error) {
+	path := hostfs.Proc("1", "mountinfo")
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Optional fields precede the separator, the filesystem type and source follow it
+		fields := strings.Fields(scanner.Text())
+		separator := -1
+		for i, field := range fields {
+			if field == "-" {
+				separator = i
+				break
+			}
+		}
+		if separator < 5 || len(fields) < separator+3 {
+			continue
+		}
+
+		mountInfos = append(mountInfos, mountInfo{
+			Mount:  unescapeMountPath(fields[4]),
+			Device: unescapeMountPath(fields[separator+2]),
+			FSType: fields[separator+1],
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	return mountInfos, nil
+}
+
+// unescapeMountPath decodes the octal escapes (e.g. \040 for a space) used in mountinfo paths
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+
+	var result strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if value, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				result.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		result.WriteByte(path[i])
+	}
+	return result.String()
+}
+
+// getMountStats retrieves space and inode usage of a mount point, resolved below the host root
+func getMountStats(target mountInfo) (Stats, error) {
+	var stat syscall.Statfs_t
+
+	if err := syscall.Statfs(hostfs.Root(target.Mount), &stat); err != nil {
+		return Stats{}, fmt.Errorf("error reading filesystem stats of %s: %v", target.Mount, err)
+	}
+
+	total := stat.Blocks * uint64(stat.Bsize)
+	free := stat.Bfree * uint64(stat.Bsize)
+	avail := stat.Bavail * uint64(stat.Bsize)
+	used := total - free
+
+	// Like df, blocks reserved for root are neither used nor available
+	var usedPerc float32
+	if used+avail > 0 {
+		usedPerc = float32(used) / float32(used+avail) * 100
+	}
+
+	inodesUsed := stat.Files - stat.Ffree
+	var inodesUsedPerc float32
+	if stat.Files > 0 {
+		inodesUsedPerc = float32(inodesUsed) / float32(stat.Files) * 100
+	}
+
+	return Stats{
+		Mount:          target.Mount,
+		Device:         target.Device,
+		FSType:         target.FSType,
+		TotalBytes:     float32(total),
+		UsedBytes:      float32(used),
+		AvailBytes:     float32(avail),
+		UsedPerc:       helpers.RoundToTwoDecimal(usedPerc),
+		InodesTotal:    float32(stat.Files),
+		InodesUsed:     float32(inodesUsed),
+		InodesUsedPerc: helpers.RoundToTwoDecimal(inodesUsedPerc),
+	}, nil
+}
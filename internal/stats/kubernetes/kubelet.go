@@ -0,0 +1,178 @@
+// internal/stats/kubernetes/kubelet.go
+
+package kubernetes
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ContainerMeta identifies a container within its pod
+type ContainerMeta struct {
+	Namespace string
+	Pod       string
+	Container string
+}
+
+// Kubelet looks up pod metadata from the /pods endpoint of the local kubelet
+type Kubelet struct {
+	url       string
+	tokenFile string
+	client    *http.Client
+	// containers maps container runtime IDs to their pod, refreshed when an unknown container shows up
+	containers map[string]ContainerMeta
+	// missing holds when containers were not listed by the kubelet, mostly pod sandboxes, so they do not cause a refresh
+	missing map[string]time.Time
+	// attemptedAt and retryDelay limit the kubelet to one request per read tick, or less after failed requests
+	attemptedAt time.Time
+	retryDelay  time.Duration
+}
+
+const (
+	// missingTTL is how long a container missing from the kubelet is not looked up again,
+	// in case the kubelet only lists it some time after its cgroup was created
+	missingTTL = time.Minute
+	// minRetryDelay and maxRetryDelay bound the backoff after failed requests, so a hung kubelet
+	// does not hold up every read tick for the client timeout
+	minRetryDelay = 10 * time.Second
+	maxRetryDelay = 5 * time.Minute
+)
+
+// errBackoff is returned by Lookup while the kubelet is not requested again after a failure
+var errBackoff = errors.New("kubelet request failed, retrying later")
+
+// podList is the subset of the kubelet /pods response that is needed to name containers
+type podList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+			UID       string `json:"uid"`
+		} `json:"metadata"`
+		Status struct {
+			ContainerStatuses     []containerStatus `json:"containerStatuses"`
+			InitContainerStatuses []containerStatus `json:"initContainerStatuses"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type containerStatus struct {
+	Name        string `json:"name"`
+	ContainerID string `json:"containerID"` // e.g. containerd://<id>
+}
+
+// NewKubelet creates a client for the kubelet at url, e.g. https://127.0.0.1:10250.
+// The bearer token is read from tokenFile on every request, so rotated service account tokens are picked up.
+func NewKubelet(url string, tokenFile string, insecureTLS bool) *Kubelet {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if insecureTLS {
+		// The kubelet serving certificate is usually self-signed
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &Kubelet{
+		url:        strings.TrimSuffix(url, "/"),
+		tokenFile:  tokenFile,
+		client:     &http.Client{Timeout: 5 * time.Second, Transport: transport},
+		containers: make(map[string]ContainerMeta),
+		missing:    make(map[string]time.Time),
+	}
+}
+
+// Lookup returns the pod metadata of a container, refreshing it from the kubelet when the container was not seen before.
+// It returns false without error for containers the kubelet does not list, such as pod sandboxes.
+func (k *Kubelet) Lookup(containerID string) (ContainerMeta, bool, error) {
+	if meta, found := k.containers[containerID]; found {
+		return meta, true, nil
+	}
+
+	if missingAt, found := k.missing[containerID]; found && time.Since(missingAt) < missingTTL {
+		return ContainerMeta{}, false, nil
+	}
+
+	// Successful requests are reused within the same read tick, failed ones are retried with a doubling delay
+	if time.Since(k.attemptedAt) < k.retryDelay {
+		if k.retryDelay > time.Second {
+			return ContainerMeta{}, false, errBackoff
+		}
+	} else {
+		k.attemptedAt = time.Now()
+		containers, err := k.fetchPods()
+		if err != nil {
+			k.retryDelay = min(max(2*k.retryDelay, minRetryDelay), maxRetryDelay)
+			return ContainerMeta{}, false, err
+		}
+		k.containers = containers
+		k.missing = make(map[string]time.Time)
+		k.retryDelay = time.Second
+	}
+
+	meta, found := k.containers[containerID]
+	if !found {
+		k.missing[containerID] = time.Now()
+	}
+	return meta, found, nil
+}
+
+// fetchPods requests the pods running on the node and indexes their containers by runtime ID
+func (k *Kubelet) fetchPods() (map[string]ContainerMeta, error) {
+	req, err := http.NewRequest("GET", k.url+"/pods", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if k.tokenFile != "" {
+		token, err := os.ReadFile(k.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			log.Printf("Error closing resp.Body: %v", err)
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kubelet returned status %d", resp.StatusCode)
+	}
+
+	var pods podList
+	if err := json.NewDecoder(resp.Body).Decode(&pods); err != nil {
+		return nil, err
+	}
+
+	containers := make(map[string]ContainerMeta)
+	for _, pod := range pods.Items {
+		statuses := append(pod.Status.ContainerStatuses, pod.Status.InitContainerStatuses...)
+		for _, status := range statuses {
+			// Strip the runtime prefix, e.g. containerd:// or cri-o://
+			_, containerID, found := strings.Cut(status.ContainerID, "://")
+			if !found {
+				continue
+			}
+			containers[containerID] = ContainerMeta{
+				Namespace: pod.Metadata.Namespace,
+				Pod:       pod.Metadata.Name,
+				Container: status.Name,
+			}
+		}
+	}
+
+	return containers, nil
+}
@@ -0,0 +1,129 @@
+// internal/stats/kubernetes/kubelet_test.go
+
+package kubernetes
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	appContainerID     = "1111111111111111111111111111111111111111111111111111111111111111"
+	initContainerID    = "2222222222222222222222222222222222222222222222222222222222222222"
+	sandboxContainerID = "3333333333333333333333333333333333333333333333333333333333333333"
+)
+
+const podsResponse = `{"items": [{
+	"metadata": {"name": "web-5d8f7", "namespace": "shop", "uid": "3f1c9a4e-2b7d-4c1a-9e8f-0a1b2c3d4e5f"},
+	"status": {
+		"containerStatuses": [{"name": "app", "containerID": "containerd://` + appContainerID + `"}],
+		"initContainerStatuses": [{"name": "migrate", "containerID": "containerd://` + initContainerID + `"}]
+	}
+}, {
+	"metadata": {"name": "pending", "namespace": "shop", "uid": "4f1c9a4e-2b7d-4c1a-9e8f-0a1b2c3d4e5f"},
+	"status": {"containerStatuses": [{"name": "app", "containerID": ""}]}
+}]}`
+
+// newTestKubelet serves the pods response and counts the requests, which must carry the token
+func newTestKubelet(t *testing.T, status int) (*Kubelet, *int) {
+	t.Helper()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/pods" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(podsResponse))
+	}))
+	t.Cleanup(server.Close)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewKubelet(server.URL+"/", tokenFile, false), &requests
+}
+
+func TestFetchPods(t *testing.T) {
+	kubelet, _ := newTestKubelet(t, http.StatusOK)
+
+	containers, err := kubelet.fetchPods()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]ContainerMeta{
+		appContainerID:  {Namespace: "shop", Pod: "web-5d8f7", Container: "app"},
+		initContainerID: {Namespace: "shop", Pod: "web-5d8f7", Container: "migrate"},
+	}
+	if len(containers) != len(expected) {
+		t.Fatalf("fetchPods() = %+v, expected %+v", containers, expected)
+	}
+	for containerID, meta := range expected {
+		if containers[containerID] != meta {
+			t.Errorf("fetchPods()[%s] = %+v, expected %+v", containerID, containers[containerID], meta)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	kubelet, requests := newTestKubelet(t, http.StatusOK)
+
+	meta, found, err := kubelet.Lookup(appContainerID)
+	if err != nil || !found || meta.Container != "app" {
+		t.Fatalf("Lookup(app) = %+v, %v, %v, expected the app container", meta, found, err)
+	}
+
+	// Known and missing containers are answered without another request
+	for i := 0; i < 3; i++ {
+		if _, found, err := kubelet.Lookup(sandboxContainerID); err != nil || found {
+			t.Fatalf("Lookup(sandbox) = %v, %v, expected not found", found, err)
+		}
+		if _, found, err := kubelet.Lookup(appContainerID); err != nil || !found {
+			t.Fatalf("Lookup(app) = %v, %v, expected found", found, err)
+		}
+		kubelet.attemptedAt = time.Time{}
+	}
+	if *requests != 1 {
+		t.Errorf("requests = %d, expected 1", *requests)
+	}
+
+	// A container that was not seen before triggers a refresh
+	if _, found, err := kubelet.Lookup("4444444444444444444444444444444444444444444444444444444444444444"); err != nil || found {
+		t.Fatalf("Lookup(new) = %v, %v, expected not found", found, err)
+	}
+	if *requests != 2 {
+		t.Errorf("requests = %d, expected 2", *requests)
+	}
+}
+
+func TestLookupError(t *testing.T) {
+	kubelet, requests := newTestKubelet(t, http.StatusInternalServerError)
+
+	// Failed requests are not cached as missing containers, but the kubelet is not requested again right away
+	for i := 0; i < 3; i++ {
+		if _, found, err := kubelet.Lookup(appContainerID); err == nil || found {
+			t.Fatalf("Lookup(app) = %v, %v, expected an error", found, err)
+		}
+	}
+	if *requests != 1 || kubelet.retryDelay != minRetryDelay {
+		t.Errorf("requests = %d, retryDelay = %v, expected 1 and %v", *requests, kubelet.retryDelay, minRetryDelay)
+	}
+
+	// The delay doubles with every failed retry
+	kubelet.attemptedAt = time.Time{}
+	if _, _, err := kubelet.Lookup(appContainerID); err == nil || errors.Is(err, errBackoff) {
+		t.Fatalf("Lookup(app) = %v, expected the kubelet error", err)
+	}
+	if *requests != 2 || kubelet.retryDelay != 2*minRetryDelay {
+		t.Errorf("requests = %d, retryDelay = %v, expected 2 and %v", *requests, kubelet.retryDelay, 2*minRetryDelay)
+	}
+}
@@ -0,0 +1,150 @@
+// internal/stats/kubernetes/stats.go
+
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/therceman/gomon/internal/cgroup"
+	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/stats/counter"
+)
+
+// Stats holds the resource usage of a container of a Kubernetes pod
+type Stats struct {
+	ContainerID      string  `json:"container_id"`        // Container runtime ID
+	Container        string  `json:"container"`           // Container name, the short ID when the kubelet does not know it
+	PodUID           string  `json:"pod_uid"`             // UID of the pod
+	Pod              string  `json:"pod"`                 // Pod name, empty without kubelet metadata
+	Namespace        string  `json:"namespace"`           // Pod namespace, empty without kubelet metadata
+	CPUReady         bool    `json:"-"`                   // False until a previous sample is available
+	CPUPerc          float32 `json:"cpu_perc"`            // CPU usage percentage, 100% is one core
+	MemMB            float32 `json:"mem_mb"`              // Memory used by the cgroup in MB
+	Tasks            uint32  `json:"tasks"`               // Number of tasks in the cgroup
+	ReadBytesPerSec  float32 `json:"read_bytes_per_sec"`  // Bytes read per second
+	WriteBytesPerSec float32 `json:"write_bytes_per_sec"` // Bytes written per second
+}
+
+// counters holds the cgroup CPU and I/O counters per container ID and field
+var counters = counter.NewSet(64)
+
+var (
+	// podPattern matches the pod level of the cgroup path, the systemd driver replaces dashes in the UID with underscores
+	podPattern = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})(\.slice)?$`)
+	// containerPattern matches the container level, e.g. cri-containerd-<id>.scope, crio-<id>.scope or plain <id>
+	containerPattern = regexp.MustCompile(`^(?:[a-z-]+-)?([0-9a-f]{64})(?:\.scope)?$`)
+	// conmonPattern matches the scope of the CRI-O container monitor, e.g. crio-conmon-<id>.scope,
+	// which has the ID of the container it monitors but is not part of its cgroup
+	conmonPattern = regexp.MustCompile(`^[a-z-]*conmon-`)
+)
+
+// GetStats retrieves the usage of the containers of the pods running on this node.
+// Pods are named through the kubelet when a client is given, otherwise only their UID is known.
+func GetStats(kubelet *Kubelet) ([]Stats, error) {
+	groups, err := cgroup.FindKubernetesContainers()
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []Stats
+	var kubeletErr error
+	for _, group := range groups {
+		podUID, containerID, err := parseGroup(group)
+		if err != nil {
+			continue // Not a container cgroup
+		}
+
+		stat, err := getContainerStats(containerID, group)
+		if err != nil {
+			// The container may stop while it is read
+			log.Printf("Error reading cgroup of container %s: %v", containerID, err)
+			continue
+		}
+		stat.PodUID = podUID
+		stat.Container = containerID[:12]
+
+		// Without an answer from the kubelet, containers keep their pod UID and short ID
+		if kubelet != nil && kubeletErr == nil {
+			meta, found, err := kubelet.Lookup(containerID)
+			if err != nil {
+				if !errors.Is(err, errBackoff) {
+					log.Printf("Error fetching pods from kubelet: %v", err)
+				}
+				kubeletErr = err
+			} else if !found {
+				continue // Pod sandboxes, i.e. pause containers, are not listed by the kubelet
+			} else {
+				stat.Namespace = meta.Namespace
+				stat.Pod = meta.Pod
+				stat.Container = meta.Container
+			}
+		}
+
+		stats = append(stats, stat)
+	}
+	counters.Prune()
+
+	return stats, nil
+}
+
+// parseGroup extracts the pod UID and container ID from the cgroup path of a container
+func parseGroup(group string) (podUID string, containerID string, err error) {
+	base := filepath.Base(group)
+	match := containerPattern.FindStringSubmatch(base)
+	if match == nil || conmonPattern.MatchString(base) {
+		return "", "", fmt.Errorf("no container ID in cgroup %s", group)
+	}
+	containerID = match[1]
+
+	podMatch := podPattern.FindStringSubmatch(filepath.Base(filepath.Dir(group)))
+	if podMatch == nil {
+		return "", "", fmt.Errorf("no pod UID in cgroup %s", group)
+	}
+	podUID = strings.ReplaceAll(podMatch[1], "_", "-")
+
+	return podUID, containerID, nil
+}
+
+// getContainerStats reads CPU, memory, I/O and task usage from the cgroup of a container
+func getContainerStats(containerID string, group string) (Stats, error) {
+	cpuUsage, err := cgroup.ReadCPUUsage(group)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	memUsage, err := cgroup.ReadMemoryUsage(group)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	// The pids controller may not be enabled for the pod
+	tasks, _ := cgroup.ReadPIDsCurrent(group)
+
+	// I/O accounting may be disabled for the pod
+	readBytes, writeBytes, _ := cgroup.ReadIOBytes(group)
+
+	stat := Stats{
+		ContainerID: containerID,
+		MemMB:       helpers.RoundToTwoDecimal(float32(memUsage) / 1024 / 1024), // Convert bytes to MB
+		Tasks:       uint32(tasks),
+	}
+
+	now := time.Now()
+	_, cpuUsecPerSec, cpuOK := counters.Update(containerID+"/cpu_usage", cpuUsage, now)
+	_, readBytesPerSec, readOK := counters.Update(containerID+"/read_bytes", readBytes, now)
+	_, writeBytesPerSec, writeOK := counters.Update(containerID+"/write_bytes", writeBytes, now)
+	if cpuOK && readOK && writeOK {
+		stat.CPUReady = true
+		stat.CPUPerc = helpers.RoundToTwoDecimal(cpuUsecPerSec / 1e6 * 100)
+		stat.ReadBytesPerSec = helpers.RoundToTwoDecimal(readBytesPerSec)
+		stat.WriteBytesPerSec = helpers.RoundToTwoDecimal(writeBytesPerSec)
+	}
+
+	return stat, nil
+}
@@ -0,0 +1,41 @@
+// internal/stats/kubernetes/stats_test.go
+
+package kubernetes
+
+import "testing"
+
+func TestParseGroup(t *testing.T) {
+	const containerID = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	const podUID = "3f1c9a4e-2b7d-4c1a-9e8f-0a1b2c3d4e5f"
+
+	tests := []struct {
+		name  string
+		group string
+		valid bool
+	}{
+		{"systemd guaranteed", "kubepods.slice/kubepods-pod3f1c9a4e_2b7d_4c1a_9e8f_0a1b2c3d4e5f.slice/cri-containerd-" + containerID + ".scope", true},
+		{"systemd burstable", "kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod3f1c9a4e_2b7d_4c1a_9e8f_0a1b2c3d4e5f.slice/crio-" + containerID + ".scope", true},
+		{"systemd besteffort docker", "kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod3f1c9a4e_2b7d_4c1a_9e8f_0a1b2c3d4e5f.slice/docker-" + containerID + ".scope", true},
+		{"cgroupfs guaranteed", "kubepods/pod3f1c9a4e-2b7d-4c1a-9e8f-0a1b2c3d4e5f/" + containerID, true},
+		{"cgroupfs burstable", "kubepods/burstable/pod3f1c9a4e-2b7d-4c1a-9e8f-0a1b2c3d4e5f/" + containerID, true},
+		{"systemd pod slice", "kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod3f1c9a4e_2b7d_4c1a_9e8f_0a1b2c3d4e5f.slice", false},
+		{"systemd CRI-O conmon", "kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod3f1c9a4e_2b7d_4c1a_9e8f_0a1b2c3d4e5f.slice/crio-conmon-" + containerID + ".scope", false},
+		{"cgroupfs QoS class", "kubepods/burstable/" + containerID, false},
+		{"short container ID", "kubepods/burstable/pod3f1c9a4e-2b7d-4c1a-9e8f-0a1b2c3d4e5f/0123456789ab", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gotPodUID, gotContainerID, err := parseGroup(test.group)
+			if !test.valid {
+				if err == nil {
+					t.Errorf("parseGroup() = %s, %s, expected an error", gotPodUID, gotContainerID)
+				}
+				return
+			}
+			if err != nil || gotPodUID != podUID || gotContainerID != containerID {
+				t.Errorf("parseGroup() = %s, %s, %v, expected %s, %s", gotPodUID, gotContainerID, err, podUID, containerID)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// internal/stats/metrics.go
+
+package stats
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/therceman/gomon/internal/psi"
+	"github.com/therceman/gomon/internal/sender/grafana"
+	"github.com/therceman/gomon/internal/stats/docker"
+	"github.com/therceman/gomon/internal/stats/sockets"
+	"github.com/therceman/gomon/internal/types"
+)
+
+// knownMetrics holds the names of the metrics reported by the collectors, which METRIC_KEYS and HISTOGRAM_METRICS
+// are validated against. Metrics named after configuration, such as log_<counter> and the exec and textfile ones,
+// are checked separately.
+var knownMetrics = make(map[string]bool)
+
+func init() {
+	collectorMetrics := [][]string{
+		// FetchSystemStats
+		{"mem_mb", "mem_perc", "disk_mb", "mem_buffers_mb", "mem_cached_mb", "mem_shared_mb", "mem_slab_mb",
+			"mem_dirty_mb", "swap_total_mb", "swap_used_mb", "swap_used_perc", "pgpgin_per_sec", "pgpgout_per_sec",
+			"pswpin_per_sec", "pswpout_per_sec", "majfault_per_sec", "cpu_perc", "cpu_user_perc", "cpu_system_perc",
+			"cpu_iowait_perc", "cpu_steal_perc", "cpu_irq_perc", "cpu_softirq_perc", "load1", "load5", "load15",
+			"uptime_sec", "procs_running", "procs_blocked", "sys_open_fds", "sys_open_fds_perc", "sys_threads",
+			"sys_threads_perc", "entropy_avail", "clock_synced", "clock_offset_ms", "clock_error_ms", "ctxt_per_sec",
+			"intr_per_sec", "forks_per_sec"},
+		// FetchDockerStats, the cgroup metrics are listed by the collector
+		{"container_tasks", "container_tasks_perc", "container_open_fds", "container_open_fds_perc"},
+		docker.MetricNames(),
+		// FetchFilesystemStats
+		{"fs_total_bytes", "fs_used_bytes", "fs_avail_bytes", "fs_used_perc", "fs_inodes_total", "fs_inodes_used",
+			"fs_inodes_used_perc"},
+		// FetchDiskIOStats
+		{"disk_read_bytes_per_sec", "disk_write_bytes_per_sec", "disk_read_iops", "disk_write_iops", "disk_await_ms",
+			"disk_util_perc"},
+		// FetchNetworkStats
+		{"net_rx_bytes_per_sec", "net_tx_bytes_per_sec", "net_rx_packets_per_sec", "net_tx_packets_per_sec",
+			"net_rx_errors_per_sec", "net_tx_errors_per_sec", "net_rx_dropped_per_sec", "net_tx_dropped_per_sec"},
+		// FetchSocketStats
+		sockets.MetricNames(),
+		// FetchSensorStats
+		{"temp_celsius", "fan_rpm"},
+		// FetchProcessStats
+		{"proc_count", "proc_threads", "proc_open_fds", "proc_open_fds_perc", "proc_read_bytes_per_sec",
+			"proc_write_bytes_per_sec"},
+		// FetchKubernetesStats
+		{"k8s_tasks", "k8s_read_bytes_per_sec", "k8s_write_bytes_per_sec"},
+		// FetchSystemdStats, besides the metrics of unitStateMetrics and unitSubStateMetrics
+		{"unit_restarts", "unit_tasks", "unit_read_bytes_per_sec", "unit_write_bytes_per_sec"},
+	}
+
+	for _, names := range collectorMetrics {
+		for _, name := range names {
+			knownMetrics[name] = true
+		}
+	}
+	for _, states := range []map[string]string{unitStateMetrics, unitSubStateMetrics} {
+		for _, name := range states {
+			knownMetrics[name] = true
+		}
+	}
+}
+
+// ValidateMetricKeys checks that every metric key selects an aggregate of a known metric and that every histogram
+// metric is known, so a typo fails at startup instead of silently exporting nothing. Metrics of the exec and textfile
+// collectors are only known once they ran, so unknown names are accepted with a warning when one of them is configured.
+func ValidateMetricKeys(config types.Config) error {
+	var unknownKeys []string
+	for _, key := range config.MetricKeys {
+		if !isKnownKey(key, config) {
+			unknownKeys = append(unknownKeys, key)
+		}
+	}
+
+	// Histograms are exported for the samples of a metric, so their names have no aggregate
+	var unknownHistograms []string
+	for _, name := range config.HistogramMetrics {
+		if !isKnownMetric(name, config) {
+			unknownHistograms = append(unknownHistograms, name)
+		}
+	}
+
+	if len(unknownKeys) == 0 && len(unknownHistograms) == 0 {
+		return nil
+	}
+
+	unknown := strings.Join(append(unknownKeys, unknownHistograms...), ",")
+	if len(config.ExecCommands) > 0 || config.TextfileDir != "" {
+		log.Printf("Metrics %s are not known, assuming they are exec or textfile metrics", unknown)
+		return nil
+	}
+
+	if len(unknownHistograms) == 0 {
+		return fmt.Errorf("unknown metric keys in METRIC_KEYS: %s", strings.Join(unknownKeys, ","))
+	}
+	if len(unknownKeys) == 0 {
+		return fmt.Errorf("unknown metrics in HISTOGRAM_METRICS: %s", strings.Join(unknownHistograms, ","))
+	}
+	return fmt.Errorf("unknown metric keys in METRIC_KEYS: %s and metrics in HISTOGRAM_METRICS: %s",
+		strings.Join(unknownKeys, ","), strings.Join(unknownHistograms, ","))
+}
+
+// isKnownKey reports whether a key names a known metric, with or without an aggregate
+func isKnownKey(key string, config types.Config) bool {
+	if isKnownMetric(key, config) {
+		return true
+	}
+
+	parts := strings.Split(key, "_")
+	for i, part := range parts {
+		if !grafana.IsAggregate(part) {
+			continue
+		}
+		if isKnownMetric(strings.Join(append(parts[:i:i], parts[i+1:]...), "_"), config) {
+			return true
+		}
+	}
+	return false
+}
+
+// isKnownMetric reports whether a collector reports a metric of the given name with the configuration
+func isKnownMetric(name string, config types.Config) bool {
+	if knownMetrics[name] || psi.MetricPattern.MatchString(name) {
+		return true
+	}
+
+	for _, pattern := range config.LogPatterns {
+		if name == "log_"+pattern.Name {
+			return true
+		}
+	}
+	return false
+}
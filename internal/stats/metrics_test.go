@@ -0,0 +1,61 @@
+// internal/stats/metrics_test.go
+
+package stats
+
+import (
+	"testing"
+
+	"github.com/therceman/gomon/internal/types"
+)
+
+func TestValidateMetricKeys(t *testing.T) {
+	logPatterns := []types.LogPattern{{Name: "errors"}}
+
+	tests := []struct {
+		name  string
+		keys  []string
+		valid bool
+	}{
+		{"default keys", []string{"cpu_max_perc", "cpu_avg_perc", "mem_max_mb", "mem_avg_mb", "mem_max_perc", "mem_avg_perc", "disk_mb"}, true},
+		{"system", []string{"load1", "cpu_iowait_max_perc", "swap_used_perc", "entropy_avail_min", "clock_offset_ms"}, true},
+		{"docker", []string{"container_open_fds_perc", "container_mem_working_set_perc", "container_oom_kills_sum", "container_cpu_throttled_ms"}, true},
+		{"filesystem and disk I/O", []string{"fs_used_perc", "fs_inodes_used_perc", "disk_util_max_perc", "disk_await_ms"}, true},
+		{"network and sockets", []string{"net_rx_bytes_per_sec", "tcp_time_wait", "tcp_retrans_per_sec", "udp_sockets"}, true},
+		{"sensors and processes", []string{"temp_celsius_max", "fan_rpm", "proc_count", "proc_count_count", "proc_open_fds_perc"}, true},
+		{"kubernetes and systemd", []string{"k8s_tasks", "unit_failed_max", "unit_sub_auto_restart", "unit_restarts"}, true},
+		{"percentiles, PSI and logs", []string{"cpu_p95_perc", "psi_io_full_stall_perc", "psi_cpu_some_avg10_perc", "log_errors"}, true},
+		{"misspelled metric", []string{"cpu_max_perc", "cpu_prec"}, false},
+		{"misspelled aggregate", []string{"cpu_maxx_perc"}, false},
+		{"unknown log counter", []string{"log_warnings"}, false},
+		{"unknown PSI resource", []string{"psi_net_some_avg10_perc"}, false},
+		{"unknown unit state", []string{"unit_sub_dead"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := types.Config{MetricKeys: test.keys, LogPatterns: logPatterns}
+			if err := ValidateMetricKeys(config); (err == nil) != test.valid {
+				t.Errorf("ValidateMetricKeys(%v) = %v, expected valid %v", test.keys, err, test.valid)
+			}
+		})
+	}
+
+	// Histograms take metric names, not keys with an aggregate
+	config := types.Config{LogPatterns: logPatterns}
+	for _, histogramMetrics := range [][]string{{"cpu_perc", "cpu_prec"}, {"cpu_max_perc"}} {
+		config.HistogramMetrics = histogramMetrics
+		if err := ValidateMetricKeys(config); err == nil {
+			t.Errorf("ValidateMetricKeys() with HistogramMetrics %v = nil, expected an error", histogramMetrics)
+		}
+	}
+	config.HistogramMetrics = []string{"cpu_perc", "mem_mb", "log_errors"}
+	if err := ValidateMetricKeys(config); err != nil {
+		t.Errorf("ValidateMetricKeys() with HistogramMetrics %v = %v, expected no error", config.HistogramMetrics, err)
+	}
+
+	// Metrics of exec commands are only known once they ran
+	config = types.Config{MetricKeys: []string{"queue_depth"}, TextfileDir: "/var/lib/gomon"}
+	if err := ValidateMetricKeys(config); err != nil {
+		t.Errorf("ValidateMetricKeys() with a textfile directory = %v, expected no error", err)
+	}
+}
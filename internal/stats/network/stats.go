@@ -0,0 +1,147 @@
+// internal/stats/network/stats.go
+
+package network
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/hostfs"
+	"github.com/therceman/gomon/internal/stats/counter"
+)
+
+// Stats holds the traffic of a network interface between two read ticks
+type Stats struct {
+	Interface       string  `json:"interface"`          // Interface name, e.g. eth0
+	RxBytesPerSec   float32 `json:"rx_bytes_per_sec"`   // Bytes received per second
+	TxBytesPerSec   float32 `json:"tx_bytes_per_sec"`   // Bytes transmitted per second
+	RxPacketsPerSec float32 `json:"rx_packets_per_sec"` // Packets received per second
+	TxPacketsPerSec float32 `json:"tx_packets_per_sec"` // Packets transmitted per second
+	RxErrorsPerSec  float32 `json:"rx_errors_per_sec"`  // Receive errors per second
+	TxErrorsPerSec  float32 `json:"tx_errors_per_sec"`  // Transmit errors per second
+	RxDroppedPerSec float32 `json:"rx_dropped_per_sec"` // Received packets dropped per second
+	TxDroppedPerSec float32 `json:"tx_dropped_per_sec"` // Transmitted packets dropped per second
+}
+
+// interfaceSample holds the /proc/net/dev counters of an interface kept between read ticks
+type interfaceSample struct {
+	rxBytes   uint64
+	rxPackets uint64
+	rxErrors  uint64
+	rxDropped uint64
+	txBytes   uint64
+	txPackets uint64
+	txErrors  uint64
+	txDropped uint64
+}
+
+// counters holds the /proc/net/dev counters per interface and field
+var counters = counter.NewSet(counter.NativeBits)
+
+// GetStats retrieves traffic rates of the interfaces matching include and not matching exclude,
+// either pattern may be nil. There are no stats on the first call, it only records the counters.
+func GetStats(include *regexp.Regexp, exclude *regexp.Regexp) ([]Stats, error) {
+	samples, err := getSamples(include, exclude)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	var stats []Stats
+	for name, sample := range samples {
+		// Interfaces that just appeared have no previous counters yet
+		ready := true
+		perSec := func(field string, value uint64) float32 {
+			_, rate, ok := counters.Update(name+"/"+field, value, now)
+			ready = ready && ok
+			return helpers.RoundToTwoDecimal(rate)
+		}
+
+		stat := Stats{
+			Interface:       name,
+			RxBytesPerSec:   perSec("rx_bytes", sample.rxBytes),
+			TxBytesPerSec:   perSec("tx_bytes", sample.txBytes),
+			RxPacketsPerSec: perSec("rx_packets", sample.rxPackets),
+			TxPacketsPerSec: perSec("tx_packets", sample.txPackets),
+			RxErrorsPerSec:  perSec("rx_errors", sample.rxErrors),
+			TxErrorsPerSec:  perSec("tx_errors", sample.txErrors),
+			RxDroppedPerSec: perSec("rx_dropped", sample.rxDropped),
+			TxDroppedPerSec: perSec("tx_dropped", sample.txDropped),
+		}
+		if ready {
+			stats = append(stats, stat)
+		}
+	}
+	counters.Prune()
+
+	return stats, nil
+}
+
+// getSamples reads the counters of the selected interfaces in the network namespace of the host init process.
+// /proc/net is a link to the namespace of gomon itself, which is not the host one when it runs in a container.
+func getSamples(include *regexp.Regexp, exclude *regexp.Regexp) (samples map[string]interfaceSample, err error) {
+	path := hostfs.Proc("1", "net", "dev")
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	samples = make(map[string]interfaceSample)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// The two header lines have no colon separating the interface name
+		name, columns, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+
+		name = strings.TrimSpace(name)
+		if include != nil && !include.MatchString(name) {
+			continue
+		}
+		if exclude != nil && exclude.MatchString(name) {
+			continue
+		}
+
+		fields := strings.Fields(columns)
+		if len(fields) < 16 {
+			return nil, fmt.Errorf("unexpected format in %s for %s", path, name)
+		}
+
+		var values [16]uint64
+		for i := range values {
+			values[i], err = strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		samples[name] = interfaceSample{
+			rxBytes:   values[0],
+			rxPackets: values[1],
+			rxErrors:  values[2],
+			rxDropped: values[3],
+			txBytes:   values[8],
+			txPackets: values[9],
+			txErrors:  values[10],
+			txDropped: values[11],
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	return samples, nil
+}
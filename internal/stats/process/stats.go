@@ -0,0 +1,291 @@
+// internal/stats/process/stats.go
+
+package process
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/therceman/gomon/internal/cgroup"
+	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/hostfs"
+	"github.com/therceman/gomon/internal/stats/counter"
+	"github.com/therceman/gomon/internal/types"
+)
+
+// ClockTicks is the USER_HZ used by /proc/<pid>/stat, fixed at 100 on Linux
+const ClockTicks = 100
+
+// Stats holds the aggregated usage of all processes selected by a matcher
+type Stats struct {
+	Name             string  `json:"name"`                // Name of the matcher
+	Count            uint32  `json:"count"`               // Number of matching processes
+	CPUReady         bool    `json:"-"`                   // False until a previous sample is available
+	CPUPerc          float32 `json:"cpu_perc"`            // CPU usage percentage, 100% is one core
+	MemMB            float32 `json:"mem_mb"`              // Resident memory in MB
+	Threads          uint32  `json:"threads"`             // Number of threads
+	FDReady          bool    `json:"-"`                   // False when the file descriptors of a process were not readable
+	OpenFDs          uint32  `json:"open_fds"`            // Number of open file descriptors
+	OpenFDsPerc      float32 `json:"open_fds_perc"`       // Highest share of the soft open files limit used by a process
+	ReadBytesPerSec  float32 `json:"read_bytes_per_sec"`  // Bytes read from storage per second
+	WriteBytesPerSec float32 `json:"write_bytes_per_sec"` // Bytes written to storage per second
+}
+
+var (
+	// counters holds the CPU and I/O counters per matcher name, PID and field
+	counters = counter.NewSet(64)
+	// readMatchers keeps the matchers read before, whose rates are known even when no process was running
+	readMatchers = make(map[string]bool)
+)
+
+// GetStats resolves the processes of each matcher and aggregates their usage.
+// Processes are resolved on every call, so restarted processes are picked up.
+func GetStats(matchers []types.ProcessMatcher) ([]Stats, error) {
+	var stats []Stats
+	for _, matcher := range matchers {
+		pids, err := resolvePIDs(matcher)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving processes of %s: %v", matcher.Name, err)
+		}
+		stats = append(stats, getMatcherStats(matcher.Name, pids))
+	}
+	counters.Prune()
+
+	return stats, nil
+}
+
+// getMatcherStats aggregates the usage of the given processes
+func getMatcherStats(name string, pids []uint32) Stats {
+	result := Stats{Name: name}
+	now := time.Now()
+
+	var ticksPerSec, readBytesPerSec, writeBytesPerSec float32
+	fdReadable := true
+	for _, pid := range pids {
+		pidStr := helpers.ConvertUint32ToString(pid)
+
+		// Processes may exit while they are read
+		pidTicks, err := ReadCPUTicks(hostfs.Proc(pidStr, "stat"))
+		if err != nil {
+			continue
+		}
+
+		status, err := readStatus(pidStr)
+		if err != nil {
+			continue
+		}
+
+		io := readIO(pidStr)
+
+		result.Count++
+		result.MemMB += float32(status["VmRSS"]) / 1024 // Convert KB to MB
+		result.Threads += uint32(status["Threads"])
+		// A count missing the descriptors of some processes would look healthier than it is
+		if openFDs, fdLimit, err := ReadFDUsage(pidStr); err != nil {
+			fdReadable = false
+		} else {
+			result.OpenFDs += openFDs
+			if fdLimit > 0 {
+				result.OpenFDsPerc = max(result.OpenFDsPerc, helpers.RoundToTwoDecimal(float32(openFDs)/float32(fdLimit)*100))
+			}
+		}
+
+		// Only processes present in both readings contribute to the rates
+		key := name + "/" + pidStr
+		if _, rate, ok := counters.Update(key+"/ticks", pidTicks, now); ok {
+			ticksPerSec += rate
+		}
+		if _, rate, ok := counters.Update(key+"/read_bytes", io["read_bytes"], now); ok {
+			readBytesPerSec += rate
+		}
+		if _, rate, ok := counters.Update(key+"/write_bytes", io["write_bytes"], now); ok {
+			writeBytesPerSec += rate
+		}
+	}
+	result.MemMB = helpers.RoundToTwoDecimal(result.MemMB)
+	result.FDReady = fdReadable
+
+	if readMatchers[name] {
+		result.CPUReady = true
+		result.CPUPerc = helpers.RoundToTwoDecimal(ticksPerSec / ClockTicks * 100)
+		result.ReadBytesPerSec = helpers.RoundToTwoDecimal(readBytesPerSec)
+		result.WriteBytesPerSec = helpers.RoundToTwoDecimal(writeBytesPerSec)
+	}
+	readMatchers[name] = true
+
+	return result
+}
+
+// resolvePIDs returns the PIDs currently selected by the matcher
+func resolvePIDs(matcher types.ProcessMatcher) ([]uint32, error) {
+	switch matcher.Kind {
+	case "pidfile":
+		data, err := os.ReadFile(hostfs.Root(matcher.Value))
+		if err != nil {
+			return nil, nil // Not running
+		}
+		// pidfiles such as postmaster.pid hold more than the PID
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 {
+			return nil, nil
+		}
+		pid, err := helpers.ConvertStringToUint32(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(hostfs.Proc(fields[0])); err != nil {
+			return nil, nil // Stale pidfile
+		}
+		return []uint32{pid}, nil
+	case "cgroup":
+		pids, err := cgroup.ReadProcs(matcher.Value)
+		if err != nil {
+			return nil, nil // Not running
+		}
+		return pids, nil
+	}
+
+	entries, err := os.ReadDir(hostfs.ProcDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []uint32
+	for _, entry := range entries {
+		pid, err := helpers.ConvertStringToUint32(entry.Name())
+		if err != nil {
+			continue // Not a process directory
+		}
+
+		var matched bool
+		if matcher.Kind == "name" {
+			comm, err := os.ReadFile(hostfs.Proc(entry.Name(), "comm"))
+			matched = err == nil && strings.TrimSpace(string(comm)) == matcher.Value
+		} else {
+			cmdline, err := os.ReadFile(hostfs.Proc(entry.Name(), "cmdline"))
+			// Arguments are separated by NUL bytes, kernel threads have no cmdline
+			matched = err == nil && len(cmdline) > 0 &&
+				matcher.Pattern.MatchString(strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " ")))
+		}
+
+		if matched {
+			pids = append(pids, pid)
+		}
+	}
+
+	return pids, nil
+}
+
+// ReadCPUTicks reads the user and system time of a process from its stat file, e.g. /proc/<pid>/stat
+func ReadCPUTicks(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	// The command name may contain spaces, so fields are counted after its closing parenthesis
+	content := string(data)
+	fields := strings.Fields(content[strings.LastIndex(content, ")")+1:])
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("unexpected format in %s", path)
+	}
+
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return utime + stime, nil
+}
+
+// readStatus parses the numeric values of /proc/<pid>/status, e.g. VmRSS in KB and Threads
+func readStatus(pidStr string) (values map[string]uint64, err error) {
+	file, err := os.Open(hostfs.Proc(pidStr, "status"))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	values = make(map[string]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[strings.TrimSuffix(fields[0], ":")] = value
+		}
+	}
+
+	return values, scanner.Err()
+}
+
+// readIO parses /proc/<pid>/io, which is only readable for processes of the same user or as root
+func readIO(pidStr string) map[string]uint64 {
+	values := make(map[string]uint64)
+
+	data, err := os.ReadFile(hostfs.Proc(pidStr, "io"))
+	if err != nil {
+		return values
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		if counter, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64); err == nil {
+			values[key] = counter
+		}
+	}
+
+	return values
+}
+
+// ReadFDUsage returns the number of open file descriptors of a process and its soft limit.
+// The descriptors are only readable for processes of the same user or as root, otherwise an error is returned.
+// The limit is 0 when it is not readable or unlimited.
+func ReadFDUsage(pidStr string) (uint32, uint64, error) {
+	entries, err := os.ReadDir(hostfs.Proc(pidStr, "fd"))
+	if err != nil {
+		return 0, 0, err
+	}
+	openFDs := uint32(len(entries))
+
+	data, err := os.ReadFile(hostfs.Proc(pidStr, "limits"))
+	if err != nil {
+		return openFDs, 0, nil
+	}
+
+	// The line looks like "Max open files            1024                 524288               files"
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "Max open files") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "Max open files"))
+		if len(fields) == 0 {
+			break
+		}
+		limit, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			break // unlimited
+		}
+		return openFDs, limit, nil
+	}
+
+	return openFDs, 0, nil
+}
@@ -0,0 +1,153 @@
+// internal/stats/sensors/stats.go
+
+package sensors
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/therceman/gomon/internal/helpers"
+)
+
+// Stats holds the reading of a single temperature or fan sensor
+type Stats struct {
+	ID          string  `json:"id"`           // Unique sensor ID, e.g. hwmon0_temp1 or thermal_zone0
+	Chip        string  `json:"chip"`         // Name of the chip or thermal zone type, e.g. coretemp
+	Label       string  `json:"label"`        // Sensor label, e.g. Core 0, falls back to the input name
+	Kind        string  `json:"kind"`         // Either temp or fan
+	TempCelsius float32 `json:"temp_celsius"` // Temperature, set for temp sensors
+	FanRPM      float32 `json:"fan_rpm"`      // Fan speed, set for fan sensors
+}
+
+// GetStats reads the hwmon temperature and fan sensors and the thermal zones below the sysfs root, usually /sys.
+// Sensors that cannot be read, e.g. because the device is asleep, are skipped.
+func GetStats(sysDir string) ([]Stats, error) {
+	hwmonStats, err := getHwmonStats(filepath.Join(sysDir, "class", "hwmon"))
+	if err != nil {
+		return nil, err
+	}
+
+	thermalStats, err := getThermalStats(filepath.Join(sysDir, "class", "thermal"))
+	if err != nil {
+		return nil, err
+	}
+
+	return append(hwmonStats, thermalStats...), nil
+}
+
+// getHwmonStats reads temp*_input and fan*_input files of every hwmon device
+func getHwmonStats(hwmonDir string) ([]Stats, error) {
+	devices, err := filepath.Glob(filepath.Join(hwmonDir, "hwmon*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(devices)
+
+	var stats []Stats
+	for _, device := range devices {
+		deviceName := filepath.Base(device)
+		chip := readString(filepath.Join(device, "name"))
+		if chip == "" {
+			chip = deviceName
+		}
+
+		inputs, err := filepath.Glob(filepath.Join(device, "*_input"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(inputs)
+
+		for _, input := range inputs {
+			// e.g. temp1_input is labeled by temp1_label
+			sensor := strings.TrimSuffix(filepath.Base(input), "_input")
+			kind := strings.TrimRight(sensor, "0123456789")
+			if kind != "temp" && kind != "fan" {
+				continue
+			}
+
+			value, err := readFloat(input)
+			if err != nil {
+				continue
+			}
+
+			label := readString(filepath.Join(device, sensor+"_label"))
+			if label == "" {
+				label = sensor
+			}
+
+			stat := Stats{
+				ID:    deviceName + "_" + sensor,
+				Chip:  chip,
+				Label: label,
+				Kind:  kind,
+			}
+			if kind == "temp" {
+				stat.TempCelsius = helpers.RoundToTwoDecimal(value / 1000) // Convert millidegree to degree
+			} else {
+				stat.FanRPM = value
+			}
+			stats = append(stats, stat)
+		}
+	}
+
+	return stats, nil
+}
+
+// getThermalStats reads the temperature of every thermal zone
+func getThermalStats(thermalDir string) ([]Stats, error) {
+	zones, err := filepath.Glob(filepath.Join(thermalDir, "thermal_zone*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(zones)
+
+	var stats []Stats
+	for _, zone := range zones {
+		value, err := readFloat(filepath.Join(zone, "temp"))
+		if err != nil {
+			continue
+		}
+
+		zoneName := filepath.Base(zone)
+		zoneType := readString(filepath.Join(zone, "type"))
+		if zoneType == "" {
+			zoneType = zoneName
+		}
+
+		stats = append(stats, Stats{
+			ID:          zoneName,
+			Chip:        zoneType,
+			Label:       zoneName,
+			Kind:        "temp",
+			TempCelsius: helpers.RoundToTwoDecimal(value / 1000), // Convert millidegree to degree
+		})
+	}
+
+	return stats, nil
+}
+
+// readString reads a single line sysfs attribute, returning an empty string when it is missing
+func readString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readFloat reads a numeric sysfs attribute
+func readFloat(path string) (float32, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 32)
+	if err != nil {
+		return 0, err
+	}
+	return float32(value), nil
+}
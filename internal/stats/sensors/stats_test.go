@@ -0,0 +1,58 @@
+// internal/stats/sensors/stats_test.go
+
+package sensors
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/therceman/gomon/internal/hostfs/hostfstest"
+)
+
+func TestGetStats(t *testing.T) {
+	sysDir := t.TempDir()
+	hostfstest.WriteFiles(t, sysDir, map[string]string{
+		// Labeled temperatures and a fan without label
+		"class/hwmon/hwmon0/name":        "coretemp\n",
+		"class/hwmon/hwmon0/temp1_input": "45500\n",
+		"class/hwmon/hwmon0/temp1_label": "Package id 0\n",
+		"class/hwmon/hwmon0/temp2_input": "-2250\n",
+		"class/hwmon/hwmon0/fan1_input":  "1200\n",
+		// Inputs other than temperatures and fans are ignored
+		"class/hwmon/hwmon0/in0_input": "900\n",
+		// A chip without name and a sensor that cannot be read
+		"class/hwmon/hwmon1/temp1_input": "38000\n",
+		"class/hwmon/hwmon1/temp2_input": "N/A\n",
+		// Thermal zones with and without type
+		"class/thermal/thermal_zone0/type": "x86_pkg_temp\n",
+		"class/thermal/thermal_zone0/temp": "51000\n",
+		"class/thermal/thermal_zone1/temp": "27800\n",
+	})
+
+	stats, err := GetStats(sysDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Stats{
+		{ID: "hwmon0_fan1", Chip: "coretemp", Label: "fan1", Kind: "fan", FanRPM: 1200},
+		{ID: "hwmon0_temp1", Chip: "coretemp", Label: "Package id 0", Kind: "temp", TempCelsius: 45.5},
+		{ID: "hwmon0_temp2", Chip: "coretemp", Label: "temp2", Kind: "temp", TempCelsius: -2.25},
+		{ID: "hwmon1_temp1", Chip: "hwmon1", Label: "temp1", Kind: "temp", TempCelsius: 38},
+		{ID: "thermal_zone0", Chip: "x86_pkg_temp", Label: "thermal_zone0", Kind: "temp", TempCelsius: 51},
+		{ID: "thermal_zone1", Chip: "thermal_zone1", Label: "thermal_zone1", Kind: "temp", TempCelsius: 27.8},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("GetStats() = %+v, expected %+v", stats, expected)
+	}
+}
+
+func TestGetStatsWithoutSensors(t *testing.T) {
+	stats, err := GetStats(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 0 {
+		t.Errorf("GetStats() = %+v, expected no sensors", stats)
+	}
+}
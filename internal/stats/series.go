@@ -0,0 +1,126 @@
+// internal/stats/series.go
+
+package stats
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/sender/grafana"
+	"github.com/therceman/gomon/internal/sketch"
+	"github.com/therceman/gomon/internal/types"
+)
+
+// Metrics that need more than the fixed aggregates, as their sketch or histogram buckets take memory in every series
+var (
+	// sketchedMetrics are selected with a percentile in METRIC_KEYS, e.g. cpu_perc by cpu_p95_perc
+	sketchedMetrics = make(map[string]bool)
+	// histogramMetrics are exported as histograms with the histogramBounds as bucket upper bounds
+	histogramMetrics = make(map[string]bool)
+	histogramBounds  []float64
+)
+
+// ConfigureAggregation selects the metrics that keep a sketch or histogram buckets besides the fixed aggregates
+func ConfigureAggregation(config types.Config) {
+	for _, key := range config.MetricKeys {
+		if name, found := grafana.PercentileMetric(key); found {
+			sketchedMetrics[name] = true
+		}
+	}
+	for _, name := range config.HistogramMetrics {
+		histogramMetrics[name] = true
+	}
+	histogramBounds = config.HistogramBuckets
+}
+
+// seriesKey identifies a series in the stats map, as IDs are only unique within their group
+func seriesKey(group string, ID string) string {
+	return group + "/" + ID
+}
+
+// getSeries returns the series with the given ID in the group, creating it with the name and tags on first use
+func getSeries(statsMap map[string]*types.Series, ID string, name string, group string, tags map[string]string) *types.Series {
+	key := seriesKey(group, ID)
+	if existing, found := statsMap[key]; found {
+		return existing
+	}
+
+	series := &types.Series{
+		ID:      ID,
+		Name:    name,
+		Group:   group,
+		Tags:    tags,
+		Metrics: make(map[string]*types.Metric),
+	}
+	statsMap[key] = series
+	return series
+}
+
+// updateMetric adds a gauge sample to the named metric of the series
+func updateMetric(series *types.Series, name string, value float32) {
+	addSample(series, name, types.Gauge, value)
+}
+
+// updateCounter adds the increase of a counter since the previous read tick to the named metric of the series
+func updateCounter(series *types.Series, name string, increase float32) {
+	addSample(series, name, types.Counter, increase)
+}
+
+// addSample adds a sample to the named metric of the series, every metric is aggregated the same way whatever its kind
+func addSample(series *types.Series, name string, kind types.MetricKind, value float32) {
+	now := time.Now()
+
+	existing, found := series.Metrics[name]
+	if !found {
+		existing = &types.Metric{Kind: kind, Min: value, Max: value, First: value, FirstAt: now}
+		if sketchedMetrics[name] {
+			existing.Sketch = sketch.New()
+		}
+		if histogramMetrics[name] {
+			existing.Buckets = make([]uint32, len(histogramBounds))
+		}
+		series.Metrics[name] = existing
+	}
+
+	if value < existing.Min {
+		existing.Min = value
+	}
+	if value > existing.Max {
+		existing.Max = value
+	}
+	existing.Last = value
+	existing.Count++
+	existing.Duration = helpers.RoundToTwoDecimal(float32(now.Sub(existing.FirstAt).Seconds()))
+
+	// Welford's online algorithm keeps the mean and variance accurate without storing the samples
+	delta := float64(value) - existing.Mean
+	existing.Mean += delta / float64(existing.Count)
+	existing.M2 += delta * (float64(value) - existing.Mean)
+	existing.Avg = helpers.RoundToTwoDecimal(float32(existing.Mean))
+	existing.Sum = helpers.RoundToTwoDecimal(float32(existing.Mean * float64(existing.Count)))
+	existing.StdDev = helpers.RoundToTwoDecimal(float32(math.Sqrt(existing.M2 / float64(existing.Count))))
+
+	if existing.Sketch != nil {
+		existing.Sketch.Add(float64(value))
+	}
+	// The first bound that is not below the value is the upper bound of its bucket
+	if i := sort.SearchFloat64s(histogramBounds, float64(value)); i < len(existing.Buckets) {
+		existing.Buckets[i]++
+	}
+}
+
+// updateMetrics adds gauge samples keyed by metric name to the series, as reported by collectors such as PSI
+func updateMetrics(series *types.Series, samples map[string]float32) {
+	for name, value := range samples {
+		updateMetric(series, name, value)
+	}
+}
+
+// updateCounters adds counter increases keyed by metric name to the series
+func updateCounters(series *types.Series, increases map[string]float32) {
+	for name, increase := range increases {
+		updateCounter(series, name, increase)
+	}
+}
@@ -0,0 +1,77 @@
+// internal/stats/series_test.go
+
+package stats
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/therceman/gomon/internal/types"
+)
+
+func TestGetSeries(t *testing.T) {
+	statsMap := make(map[string]*types.Series)
+
+	// IDs are only unique within a group, e.g. a core and a network interface named alike
+	core := getSeries(statsMap, "eth0", "eth0", "system", nil)
+	iface := getSeries(statsMap, "eth0", "eth0", "network", nil)
+	if core == iface {
+		t.Fatal("getSeries() returned the same series for two groups")
+	}
+	if getSeries(statsMap, "eth0", "other", "system", nil) != core {
+		t.Error("getSeries() did not return the existing series")
+	}
+	if len(statsMap) != 2 {
+		t.Errorf("len(statsMap) = %d, expected 2", len(statsMap))
+	}
+}
+
+func TestAddSample(t *testing.T) {
+	series := getSeries(make(map[string]*types.Series), "web", "web", "docker", nil)
+	for _, value := range []float32{2, 4, 6} {
+		updateMetric(series, "cpu_perc", value)
+		updateCounter(series, "log_errors", value)
+	}
+
+	gauge := series.Metrics["cpu_perc"]
+	if gauge.Kind != types.Gauge || gauge.Min != 2 || gauge.Max != 6 || gauge.Avg != 4 || gauge.Sum != 12 || gauge.Count != 3 {
+		t.Errorf("cpu_perc = %+v, expected a gauge with min 2, max 6, avg 4, sum 12 and count 3", gauge)
+	}
+	if counter := series.Metrics["log_errors"]; counter.Kind != types.Counter || counter.Sum != 12 {
+		t.Errorf("log_errors = %+v, expected a counter with sum 12", counter)
+	}
+}
+
+func TestConfigureAggregation(t *testing.T) {
+	ConfigureAggregation(types.Config{
+		MetricKeys:       []string{"cpu_max_perc", "cpu_p95_perc", "load1_p50"},
+		HistogramMetrics: []string{"mem_mb"},
+		HistogramBuckets: []float64{10, 100},
+	})
+	t.Cleanup(func() {
+		sketchedMetrics = make(map[string]bool)
+		histogramMetrics = make(map[string]bool)
+		histogramBounds = nil
+	})
+
+	series := getSeries(make(map[string]*types.Series), "system", "system", "system", nil)
+	for _, value := range []float32{5, 10, 50, 500} {
+		for _, name := range []string{"cpu_perc", "load1", "mem_mb", "disk_mb"} {
+			updateMetric(series, name, value)
+		}
+	}
+
+	for name, sketched := range map[string]bool{"cpu_perc": true, "load1": true, "mem_mb": false, "disk_mb": false} {
+		if (series.Metrics[name].Sketch != nil) != sketched {
+			t.Errorf("%s has sketch %v, expected %v", name, series.Metrics[name].Sketch != nil, sketched)
+		}
+	}
+
+	// Values on a bound belong to its bucket, values above the last bound are only counted
+	if buckets := series.Metrics["mem_mb"].Buckets; !reflect.DeepEqual(buckets, []uint32{2, 1}) {
+		t.Errorf("mem_mb buckets = %v, expected [2 1]", buckets)
+	}
+	if buckets := series.Metrics["cpu_perc"].Buckets; buckets != nil {
+		t.Errorf("cpu_perc buckets = %v, expected none", buckets)
+	}
+}
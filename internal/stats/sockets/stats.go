@@ -0,0 +1,240 @@
+// internal/stats/sockets/stats.go
+
+package sockets
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/stats/counter"
+)
+
+// Stats holds the socket summary of a network namespace
+type Stats struct {
+	ID string `json:"id"` // Identifier of the namespace owner, e.g. system or a container ID
+	// Socket counts and error rates keyed by metric name, e.g. tcp_time_wait
+	Metrics map[string]float32 `json:"metrics"`
+}
+
+// tcpStates maps the hex state codes of /proc/net/tcp to metric names
+var tcpStates = map[string]string{
+	"01": "tcp_established",
+	"02": "tcp_syn_sent",
+	"03": "tcp_syn_recv",
+	"04": "tcp_fin_wait1",
+	"05": "tcp_fin_wait2",
+	"06": "tcp_time_wait",
+	"07": "tcp_close",
+	"08": "tcp_close_wait",
+	"09": "tcp_last_ack",
+	"0A": "tcp_listen",
+	"0B": "tcp_closing",
+}
+
+// snmpCounters maps the /proc/net/snmp counters converted to rates to metric names
+var snmpCounters = map[string]string{
+	"Tcp:RetransSegs":  "tcp_retrans_per_sec",
+	"Tcp:InErrs":       "tcp_in_errs_per_sec",
+	"Tcp:OutRsts":      "tcp_out_rsts_per_sec",
+	"Tcp:AttemptFails": "tcp_attempt_fails_per_sec",
+	"Tcp:EstabResets":  "tcp_estab_resets_per_sec",
+	"Udp:InErrors":     "udp_in_errors_per_sec",
+	"Udp:NoPorts":      "udp_no_ports_per_sec",
+	"Udp:RcvbufErrors": "udp_rcvbuf_errors_per_sec",
+	"Udp:SndbufErrors": "udp_sndbuf_errors_per_sec",
+}
+
+// MetricNames returns the names of the metrics reported by GetStats
+func MetricNames() []string {
+	names := []string{"udp_sockets"}
+	for _, name := range tcpStates {
+		names = append(names, name)
+	}
+	for _, name := range snmpCounters {
+		names = append(names, name)
+	}
+	return names
+}
+
+// counters holds the /proc/net/snmp counters per namespace owner and counter
+var counters = counter.NewSet(counter.NativeBits)
+
+// GetStats summarises the sockets of each target, a map of namespace owner ID to
+// the proc directory whose net subdirectory is read, e.g. /proc/1 for the host or /proc/<pid>.
+// Error rates are only reported from the second call on, unreadable targets are skipped.
+func GetStats(targets map[string]string) ([]Stats, error) {
+	var stats []Stats
+	for ID, procDir := range targets {
+		metrics, err := getTargetStats(ID, procDir)
+		if err != nil {
+			// The process owning a container namespace may exit at any time
+			log.Printf("Error reading sockets of %s: %v", ID, err)
+			continue
+		}
+		stats = append(stats, Stats{ID: ID, Metrics: metrics})
+	}
+
+	// Targets that are gone are forgotten
+	counters.Prune()
+
+	return stats, nil
+}
+
+// getTargetStats counts the sockets of a single namespace and calculates its error rates
+func getTargetStats(ID string, procDir string) (map[string]float32, error) {
+	metrics := make(map[string]float32)
+
+	for _, name := range tcpStates {
+		metrics[name] = 0
+	}
+	for _, file := range []string{"tcp", "tcp6"} {
+		if err := countTCPStates(filepath.Join(procDir, "net", file), metrics); err != nil {
+			return nil, err
+		}
+	}
+
+	var udpSockets float32
+	for _, file := range []string{"udp", "udp6"} {
+		count, err := countLines(filepath.Join(procDir, "net", file))
+		if err != nil {
+			return nil, err
+		}
+		udpSockets += count
+	}
+	metrics["udp_sockets"] = udpSockets
+
+	values, err := getSNMPCounters(filepath.Join(procDir, "net", "snmp"))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for key, name := range snmpCounters {
+		if _, rate, ok := counters.Update(ID+"/"+key, values[key], now); ok {
+			metrics[name] = helpers.RoundToTwoDecimal(rate)
+		}
+	}
+
+	return metrics, nil
+}
+
+// countTCPStates adds the number of sockets per state listed in a /proc/net/tcp file to metrics
+func countTCPStates(path string, metrics map[string]float32) (err error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil // IPv6 may be disabled
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	scanner := bufio.NewScanner(file)
+	// Skip the header line
+	scanner.Scan()
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		if name, found := tcpStates[fields[3]]; found {
+			metrics[name]++
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// countLines returns the number of sockets listed in a /proc/net/udp file
+func countLines(path string) (count float32, err error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil // IPv6 may be disabled
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	scanner := bufio.NewScanner(file)
+	// Skip the header line
+	scanner.Scan()
+
+	for scanner.Scan() {
+		count++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	return count, nil
+}
+
+// getSNMPCounters reads /proc/net/snmp, where each protocol has a header line followed by a value line
+func getSNMPCounters(path string) (counters map[string]uint64, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	counters = make(map[string]uint64)
+	headers := make(map[string][]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		protocol := fields[0]
+		names, found := headers[protocol]
+		if !found {
+			headers[protocol] = fields[1:]
+			continue
+		}
+
+		for i, value := range fields[1:] {
+			if i >= len(names) {
+				break
+			}
+			// Some values such as Tcp MaxConn are signed, they are not needed
+			if counter, err := strconv.ParseUint(value, 10, 64); err == nil {
+				counters[protocol+names[i]] = counter
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	return counters, nil
+}
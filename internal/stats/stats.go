@@ -4,21 +4,39 @@ package stats
 
 import (
 	"log"
+	"regexp"
+	"sort"
 
 	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/hostfs"
 	"github.com/therceman/gomon/internal/sender/grafana"
+	"github.com/therceman/gomon/internal/stats/custom"
+	"github.com/therceman/gomon/internal/stats/diskio"
 	"github.com/therceman/gomon/internal/stats/docker"
+	"github.com/therceman/gomon/internal/stats/filesystem"
+	"github.com/therceman/gomon/internal/stats/kubernetes"
+	"github.com/therceman/gomon/internal/stats/network"
+	"github.com/therceman/gomon/internal/stats/process"
+	"github.com/therceman/gomon/internal/stats/sensors"
+	"github.com/therceman/gomon/internal/stats/sockets"
 	"github.com/therceman/gomon/internal/stats/system"
+	"github.com/therceman/gomon/internal/stats/systemd"
 	"github.com/therceman/gomon/internal/stats/worker"
 	"github.com/therceman/gomon/internal/types"
 )
 
-func FlushStats(statsMap map[string]*types.Stats, config types.Config) {
+func FlushStats(statsMap map[string]*types.Series, config types.Config) {
 	log.Println("Flushing stats map")
 
 	for _, stat := range statsMap {
 		data := grafana.PrepareInfluxData(config.MetricKeys, config.ContainerName, *stat)
-		log.Printf("data: %v\n", data)
+		if data != "" {
+			log.Printf("data: %v\n", data)
+		}
+
+		for _, histogram := range grafana.PrepareInfluxHistograms(config.HistogramMetrics, config.HistogramBuckets, config.ContainerName, *stat) {
+			log.Printf("histogram: %v\n", histogram)
+		}
 
 		//err := grafana.SendToInflux(types.GrafanaInfluxURL, types.GrafanaUsername, types.GrafanaAPIKey, data)
 		//if err != nil {
@@ -29,86 +47,58 @@ func FlushStats(statsMap map[string]*types.Stats, config types.Config) {
 	}
 }
 
-// FetchDockerStats fetches and updates Docker stats
-func FetchDockerStats(statsMap map[string]*types.Stats) error {
-	dockerStats, err := docker.GetStats()
+// FetchDockerStats fetches and updates Docker stats, initPIDs are the host PIDs of the container init processes
+func FetchDockerStats(statsMap map[string]*types.Series, logPatterns []types.LogPattern, initPIDs map[string]uint32) error {
+	dockerStats, err := docker.GetStats(logPatterns, initPIDs)
 	if err != nil {
 		return err
 	}
 
 	for _, stat := range dockerStats {
-		if existing, found := statsMap[stat.ID]; found {
-			// Update CPUPerc percentages
-			if stat.CPU < existing.CPUMinPerc {
-				existing.CPUMinPerc = stat.CPU
-			}
-			if stat.CPU > existing.CPUMaxPerc {
-				existing.CPUMaxPerc = stat.CPU
-			}
+		existing := getSeries(statsMap, stat.ID, stat.Name, "docker", nil)
+		updateMetric(existing, "mem_mb", stat.MemMB)
+		updateMetric(existing, "mem_perc", stat.MemPerc)
+		updateMetric(existing, "disk_mb", stat.SizeMB)
 
-			// Update CPU average
-			existing.CPUPercSum += stat.CPU
-			existing.CPUCount++
-			existing.CPUAvgPerc = helpers.RoundToTwoDecimal(existing.CPUPercSum / float32(existing.CPUCount))
+		if stat.CPUReady {
+			updateMetric(existing, "cpu_perc", stat.CPU)
+		}
 
-			// Update Memory usage in MB
-			if stat.MemMB < existing.MemMinMB {
-				existing.MemMinMB = stat.MemMB
-			}
-			if stat.MemMB > existing.MemMaxMB {
-				existing.MemMaxMB = stat.MemMB
+		// Update task and file descriptor usage
+		updateMetric(existing, "container_tasks", float32(stat.PIDs))
+		if stat.PIDsMax > 0 {
+			updateMetric(existing, "container_tasks_perc", helpers.RoundToTwoDecimal(float32(stat.PIDs)/float32(stat.PIDsMax)*100))
+		}
+		if stat.FDReady {
+			updateMetric(existing, "container_open_fds", float32(stat.OpenFDs))
+			if stat.FDLimit > 0 {
+				updateMetric(existing, "container_open_fds_perc", helpers.RoundToTwoDecimal(float32(stat.OpenFDs)/float32(stat.FDLimit)*100))
 			}
+		}
 
-			// Update Memory average
-			existing.MemMBPercSum += stat.MemMB
-			existing.MemCount++
-			existing.MemAvgMB = helpers.RoundToTwoDecimal(existing.MemMBPercSum / float32(existing.MemCount))
+		// Log counters hold the matches per read tick, so their sum is the number of matches in the window
+		for name, count := range stat.Logs {
+			updateCounter(existing, "log_"+name, float32(count))
+		}
 
-			// Update Memory percentages
-			if stat.MemPerc < existing.MemMinPerc {
-				existing.MemMinPerc = stat.MemPerc
-			}
-			if stat.MemPerc > existing.MemMaxPerc {
-				existing.MemMaxPerc = stat.MemPerc
-			}
+		// Update CPU quota usage and throttling
+		updateMetrics(existing, stat.CPUQuota)
 
-			// Update Memory percentage average
-			existing.MemPercSum += stat.MemPerc
-			existing.MemPercCount++
-			existing.MemAvgPerc = helpers.RoundToTwoDecimal(existing.MemPercSum / float32(existing.MemPercCount))
+		// Update memory breakdown
+		updateMetrics(existing, stat.Memory)
 
-			// Update Disk usage
-			existing.DiskMB = stat.SizeMB
-		} else {
-			statsMap[stat.ID] = &types.Stats{
-				ID:           stat.ID,
-				Name:         stat.Name,
-				Group:        "docker",
-				CPUMinPerc:   stat.CPU,
-				CPUMaxPerc:   stat.CPU,
-				CPUPercSum:   stat.CPU,
-				CPUCount:     1,
-				CPUAvgPerc:   stat.CPU,
-				MemMinMB:     stat.MemMB,
-				MemMaxMB:     stat.MemMB,
-				MemMBPercSum: stat.MemMB,
-				MemCount:     1,
-				MemAvgMB:     stat.MemMB,
-				MemMinPerc:   stat.MemPerc,
-				MemMaxPerc:   stat.MemPerc,
-				MemPercSum:   stat.MemPerc,
-				MemPercCount: 1,
-				MemAvgPerc:   stat.MemPerc,
-				DiskMB:       stat.SizeMB,
-			}
-		}
+		// Update memory events and throttled time, which are increases per read tick
+		updateCounters(existing, stat.Increases)
+
+		// Update pressure stall metrics
+		updateMetrics(existing, stat.PSI)
 	}
 
 	return nil
 }
 
 // FetchSystemStats fetches and updates system stats
-func FetchSystemStats(statsMap map[string]*types.Stats) error {
+func FetchSystemStats(statsMap map[string]*types.Series) error {
 	sysStats, err := system.GetStats()
 	if err != nil {
 		return err
@@ -117,148 +107,434 @@ func FetchSystemStats(statsMap map[string]*types.Stats) error {
 	ID := "system"
 	NAME := helpers.GetOperatingSystem()
 
-	if existing, found := statsMap[ID]; found {
-		// Update CPUPerc percentages
-		if sysStats.CPUPerc < existing.CPUMinPerc {
-			existing.CPUMinPerc = sysStats.CPUPerc
+	existing := getSeries(statsMap, ID, NAME, "system", make(map[string]string))
+	updateMetric(existing, "mem_mb", float32(sysStats.MemMB))
+	updateMetric(existing, "mem_perc", sysStats.MemPerc)
+	updateMetric(existing, "disk_mb", float32(sysStats.DiskMB))
+
+	// Update memory and swap breakdown
+	updateMetric(existing, "mem_buffers_mb", sysStats.MemBuffersMB)
+	updateMetric(existing, "mem_cached_mb", sysStats.MemCachedMB)
+	updateMetric(existing, "mem_shared_mb", sysStats.MemSharedMB)
+	updateMetric(existing, "mem_slab_mb", sysStats.MemSlabMB)
+	updateMetric(existing, "mem_dirty_mb", sysStats.MemDirtyMB)
+	updateMetric(existing, "swap_total_mb", sysStats.SwapTotalMB)
+	updateMetric(existing, "swap_used_mb", sysStats.SwapUsedMB)
+	updateMetric(existing, "swap_used_perc", sysStats.SwapUsedPerc)
+
+	// Paging rates are measured between read ticks, so there are none on the first one
+	if sysStats.VMReady {
+		updateMetric(existing, "pgpgin_per_sec", sysStats.PgpginPerSec)
+		updateMetric(existing, "pgpgout_per_sec", sysStats.PgpgoutPerSec)
+		updateMetric(existing, "pswpin_per_sec", sysStats.PswpinPerSec)
+		updateMetric(existing, "pswpout_per_sec", sysStats.PswpoutPerSec)
+		updateMetric(existing, "majfault_per_sec", sysStats.MajFaultPerSec)
+	}
+
+	// CPU usage is measured between read ticks, so there is none on the first one
+	if sysStats.CPUReady {
+		updateMetric(existing, "cpu_perc", sysStats.CPUPerc)
+
+		// Update CPU mode breakdown
+		updateMetric(existing, "cpu_user_perc", sysStats.CPUUserPerc)
+		updateMetric(existing, "cpu_system_perc", sysStats.CPUSystemPerc)
+		updateMetric(existing, "cpu_iowait_perc", sysStats.CPUIOWaitPerc)
+		updateMetric(existing, "cpu_steal_perc", sysStats.CPUStealPerc)
+		updateMetric(existing, "cpu_irq_perc", sysStats.CPUIRQPerc)
+		updateMetric(existing, "cpu_softirq_perc", sysStats.CPUSoftIRQPerc)
+
+		fetchCoreStats(statsMap, sysStats.CoresPerc)
+	}
+
+	// Update load, uptime and process counts
+	updateMetric(existing, "load1", sysStats.Load1)
+	updateMetric(existing, "load5", sysStats.Load5)
+	updateMetric(existing, "load15", sysStats.Load15)
+	updateMetric(existing, "uptime_sec", sysStats.UptimeSec)
+	updateMetric(existing, "procs_running", float32(sysStats.ProcsRunning))
+	updateMetric(existing, "procs_blocked", float32(sysStats.ProcsBlocked))
+
+	// Update file descriptor and thread usage
+	if sysStats.LimitsReady {
+		updateMetric(existing, "sys_open_fds", float32(sysStats.OpenFDs))
+		updateMetric(existing, "sys_open_fds_perc", sysStats.OpenFDsPerc)
+		updateMetric(existing, "sys_threads", float32(sysStats.Threads))
+		updateMetric(existing, "sys_threads_perc", sysStats.ThreadsPerc)
+	}
+
+	// Kernel identity is tagged, a new boot id reveals a reboot within the flush window
+	if sysStats.KernelVersion != "" {
+		existing.Tags["kernel"] = sysStats.KernelVersion
+	}
+	if sysStats.BootID != "" {
+		existing.Tags["boot_id"] = sysStats.BootID
+	}
+
+	// Update entropy and clock health
+	if sysStats.EntropyReady {
+		updateMetric(existing, "entropy_avail", float32(sysStats.EntropyAvail))
+	}
+	if sysStats.ClockReady {
+		var synced float32
+		if sysStats.ClockSynced {
+			synced = 1
 		}
-		if sysStats.CPUPerc > existing.CPUMaxPerc {
-			existing.CPUMaxPerc = sysStats.CPUPerc
+		updateMetric(existing, "clock_synced", synced)
+		updateMetric(existing, "clock_offset_ms", sysStats.ClockOffsetMs)
+		updateMetric(existing, "clock_error_ms", sysStats.ClockErrorMs)
+	}
+
+	// Rates are measured between read ticks, so there are none on the first one
+	if sysStats.RatesReady {
+		updateMetric(existing, "ctxt_per_sec", sysStats.CtxtPerSec)
+		updateMetric(existing, "intr_per_sec", sysStats.IntrPerSec)
+		updateMetric(existing, "forks_per_sec", sysStats.ForksPerSec)
+	}
+
+	// Update pressure stall metrics
+	updateMetrics(existing, sysStats.PSI)
+
+	return nil
+}
+
+// fetchCoreStats updates the per-core CPU stats, each core is tracked as its own entry named after it, e.g. cpu0
+func fetchCoreStats(statsMap map[string]*types.Series, coresPerc map[string]float32) {
+	for name, cpu := range coresPerc {
+		ID := name
+
+		existing := getSeries(statsMap, ID, name, "system", nil)
+
+		updateMetric(existing, "cpu_perc", cpu)
+	}
+}
+
+// FetchFilesystemStats fetches and updates the usage of the given mount points, all when none are given
+func FetchFilesystemStats(statsMap map[string]*types.Series, mounts []string) error {
+	fsStats, err := filesystem.GetStats(mounts)
+	if err != nil {
+		return err
+	}
+
+	for _, stat := range fsStats {
+		ID := stat.Mount
+
+		existing := getSeries(statsMap, ID, stat.Mount, "filesystem", map[string]string{
+			"mount":   stat.Mount,
+			"device":  stat.Device,
+			"fs_type": stat.FSType,
+		})
+		updateMetric(existing, "fs_total_bytes", stat.TotalBytes)
+		updateMetric(existing, "fs_used_bytes", stat.UsedBytes)
+		updateMetric(existing, "fs_avail_bytes", stat.AvailBytes)
+		updateMetric(existing, "fs_used_perc", stat.UsedPerc)
+		updateMetric(existing, "fs_inodes_total", stat.InodesTotal)
+		updateMetric(existing, "fs_inodes_used", stat.InodesUsed)
+		updateMetric(existing, "fs_inodes_used_perc", stat.InodesUsedPerc)
+	}
+
+	return nil
+}
+
+// FetchDiskIOStats fetches and updates the I/O activity of block devices
+func FetchDiskIOStats(statsMap map[string]*types.Series, include *regexp.Regexp, exclude *regexp.Regexp) error {
+	ioStats, err := diskio.GetStats(include, exclude)
+	if err != nil {
+		return err
+	}
+
+	for _, stat := range ioStats {
+		ID := stat.Device
+
+		existing := getSeries(statsMap, ID, stat.Device, "diskio", map[string]string{"device": stat.Device})
+		updateMetric(existing, "disk_read_bytes_per_sec", stat.ReadBytesPerSec)
+		updateMetric(existing, "disk_write_bytes_per_sec", stat.WriteBytesPerSec)
+		updateMetric(existing, "disk_read_iops", stat.ReadIOPS)
+		updateMetric(existing, "disk_write_iops", stat.WriteIOPS)
+		updateMetric(existing, "disk_await_ms", stat.AwaitMs)
+		updateMetric(existing, "disk_util_perc", stat.UtilPerc)
+	}
+
+	return nil
+}
+
+// FetchNetworkStats fetches and updates the traffic of network interfaces
+func FetchNetworkStats(statsMap map[string]*types.Series, include *regexp.Regexp, exclude *regexp.Regexp) error {
+	netStats, err := network.GetStats(include, exclude)
+	if err != nil {
+		return err
+	}
+
+	for _, stat := range netStats {
+		ID := stat.Interface
+
+		existing := getSeries(statsMap, ID, stat.Interface, "network", map[string]string{"interface": stat.Interface})
+		updateMetric(existing, "net_rx_bytes_per_sec", stat.RxBytesPerSec)
+		updateMetric(existing, "net_tx_bytes_per_sec", stat.TxBytesPerSec)
+		updateMetric(existing, "net_rx_packets_per_sec", stat.RxPacketsPerSec)
+		updateMetric(existing, "net_tx_packets_per_sec", stat.TxPacketsPerSec)
+		updateMetric(existing, "net_rx_errors_per_sec", stat.RxErrorsPerSec)
+		updateMetric(existing, "net_tx_errors_per_sec", stat.TxErrorsPerSec)
+		updateMetric(existing, "net_rx_dropped_per_sec", stat.RxDroppedPerSec)
+		updateMetric(existing, "net_tx_dropped_per_sec", stat.TxDroppedPerSec)
+	}
+
+	return nil
+}
+
+// FetchSocketStats fetches and updates the socket summary of the host on the system entry and,
+// when enabled, of each container on its docker entry through the host PID of its init process
+func FetchSocketStats(statsMap map[string]*types.Series, initPIDs map[string]uint32, includeContainers bool) error {
+	// The host sockets are those of the host init process, /proc/net belongs to the namespace of gomon itself
+	targets := map[string]string{"system": hostfs.Proc("1")}
+
+	if includeContainers {
+		for containerID, pid := range initPIDs {
+			targets[containerID] = hostfs.Proc(helpers.ConvertUint32ToString(pid))
 		}
+	}
 
-		// Update CPU average
-		existing.CPUPercSum += sysStats.CPUPerc
-		existing.CPUCount++
-		existing.CPUAvgPerc = helpers.RoundToTwoDecimal(existing.CPUPercSum / float32(existing.CPUCount))
+	socketStats, err := sockets.GetStats(targets)
+	if err != nil {
+		return err
+	}
 
-		// Update Memory usage in MB
-		if float32(sysStats.MemMB) < existing.MemMinMB {
-			existing.MemMinMB = float32(sysStats.MemMB)
+	for _, stat := range socketStats {
+		// Entries are created by the system and docker fetches
+		group := "docker"
+		if stat.ID == "system" {
+			group = "system"
 		}
-		if float32(sysStats.MemMB) > existing.MemMaxMB {
-			existing.MemMaxMB = float32(sysStats.MemMB)
+		existing, found := statsMap[seriesKey(group, stat.ID)]
+		if !found {
+			continue
+		}
+
+		updateMetrics(existing, stat.Metrics)
+	}
+
+	return nil
+}
+
+// FetchSensorStats fetches and updates hardware temperature and fan sensors
+func FetchSensorStats(statsMap map[string]*types.Series) error {
+	sensorStats, err := sensors.GetStats(hostfs.SysDir)
+	if err != nil {
+		return err
+	}
+
+	for _, stat := range sensorStats {
+		ID := stat.ID
+
+		existing := getSeries(statsMap, ID, stat.Label, "sensors", map[string]string{
+			"chip":  stat.Chip,
+			"label": stat.Label,
+		})
+
+		if stat.Kind == "temp" {
+			updateMetric(existing, "temp_celsius", stat.TempCelsius)
+		} else {
+			updateMetric(existing, "fan_rpm", stat.FanRPM)
 		}
+	}
+
+	return nil
+}
+
+// FetchProcessStats fetches and updates the aggregated usage of the monitored processes
+func FetchProcessStats(statsMap map[string]*types.Series, matchers []types.ProcessMatcher) error {
+	processStats, err := process.GetStats(matchers)
+	if err != nil {
+		return err
+	}
+
+	for _, stat := range processStats {
+		ID := stat.Name
 
-		// Update Memory average
-		existing.MemMBPercSum += float32(sysStats.MemMB)
-		existing.MemCount++
-		existing.MemAvgMB = helpers.RoundToTwoDecimal(existing.MemMBPercSum / float32(existing.MemCount))
+		existing := getSeries(statsMap, ID, stat.Name, "process", nil)
+		updateMetric(existing, "proc_count", float32(stat.Count))
 
-		// Update Memory percentages
-		if sysStats.MemPerc < existing.MemMinPerc {
-			existing.MemMinPerc = sysStats.MemPerc
+		// Nothing else to report while no process is running
+		if stat.Count == 0 {
+			continue
 		}
-		if sysStats.MemPerc > existing.MemMaxPerc {
-			existing.MemMaxPerc = sysStats.MemPerc
+
+		updateMetric(existing, "mem_mb", stat.MemMB)
+		updateMetric(existing, "proc_threads", float32(stat.Threads))
+		if stat.FDReady {
+			updateMetric(existing, "proc_open_fds", float32(stat.OpenFDs))
+			updateMetric(existing, "proc_open_fds_perc", stat.OpenFDsPerc)
 		}
 
-		// Update Memory percentage average
-		existing.MemPercSum += sysStats.MemPerc
-		existing.MemPercCount++
-		existing.MemAvgPerc = helpers.RoundToTwoDecimal(existing.MemPercSum / float32(existing.MemPercCount))
-
-		// Update Disk usage
-		existing.DiskMB = float32(sysStats.DiskMB)
-	} else {
-		statsMap[ID] = &types.Stats{
-			ID:           ID,
-			Name:         NAME,
-			Group:        "system",
-			CPUMinPerc:   sysStats.CPUPerc,
-			CPUMaxPerc:   sysStats.CPUPerc,
-			CPUPercSum:   sysStats.CPUPerc,
-			CPUCount:     1,
-			CPUAvgPerc:   sysStats.CPUPerc,
-			MemMinMB:     float32(sysStats.MemMB),
-			MemMaxMB:     float32(sysStats.MemMB),
-			MemMBPercSum: float32(sysStats.MemMB),
-			MemCount:     1,
-			MemAvgMB:     float32(sysStats.MemMB),
-			MemMinPerc:   sysStats.MemPerc,
-			MemMaxPerc:   sysStats.MemPerc,
-			MemPercSum:   sysStats.MemPerc,
-			MemPercCount: 1,
-			MemAvgPerc:   sysStats.MemPerc,
-			DiskMB:       float32(sysStats.DiskMB),
+		if stat.CPUReady {
+			updateMetric(existing, "cpu_perc", stat.CPUPerc)
+			updateMetric(existing, "proc_read_bytes_per_sec", stat.ReadBytesPerSec)
+			updateMetric(existing, "proc_write_bytes_per_sec", stat.WriteBytesPerSec)
 		}
 	}
 
 	return nil
 }
 
-// FetchWorkerStats fetches and updates worker stats
-func FetchWorkerStats(statsMap map[string]*types.Stats, pidStr string, pid uint32, processName string) error {
-	workerStats, err := worker.GetStats(pidStr, pid)
+// FetchKubernetesStats fetches and updates the usage of the containers of the pods running on this node
+func FetchKubernetesStats(statsMap map[string]*types.Series, kubelet *kubernetes.Kubelet) error {
+	containerStats, err := kubernetes.GetStats(kubelet)
 	if err != nil {
 		return err
 	}
 
-	ID := pidStr
-	NAME := processName
+	for _, stat := range containerStats {
+		ID := stat.ContainerID
 
-	if existing, found := statsMap[ID]; found {
-		// Update CPU percentages
-		if workerStats.CPUPerc < existing.CPUMinPerc {
-			existing.CPUMinPerc = workerStats.CPUPerc
+		tags := map[string]string{"pod_uid": stat.PodUID, "container": stat.Container}
+		if stat.Pod != "" {
+			tags["namespace"] = stat.Namespace
+			tags["pod"] = stat.Pod
 		}
-		if workerStats.CPUPerc > existing.CPUMaxPerc {
-			existing.CPUMaxPerc = workerStats.CPUPerc
+		existing := getSeries(statsMap, ID, stat.Container, "kubernetes", tags)
+		updateMetric(existing, "mem_mb", stat.MemMB)
+		updateMetric(existing, "k8s_tasks", float32(stat.Tasks))
+
+		if stat.CPUReady {
+			updateMetric(existing, "cpu_perc", stat.CPUPerc)
+			updateMetric(existing, "k8s_read_bytes_per_sec", stat.ReadBytesPerSec)
+			updateMetric(existing, "k8s_write_bytes_per_sec", stat.WriteBytesPerSec)
 		}
+	}
 
-		// Update CPU average
-		existing.CPUPercSum += workerStats.CPUPerc
-		existing.CPUCount++
-		existing.CPUAvgPerc = helpers.RoundToTwoDecimal(existing.CPUPercSum / float32(existing.CPUCount))
+	return nil
+}
 
-		// Update Memory usage in MB
-		memMB := helpers.RoundToTwoDecimal(float32(workerStats.MemKB) / 1024)
-		if memMB < existing.MemMinMB {
-			existing.MemMinMB = memMB
-		}
-		if memMB > existing.MemMaxMB {
-			existing.MemMaxMB = memMB
+// FetchExecStats adds the samples of the user supplied commands that completed since the previous read tick
+// to the exec group
+func FetchExecStats(statsMap map[string]*types.Series, runner *custom.ExecRunner) error {
+	fetchCustomStats(statsMap, "exec", runner.Results())
+	return nil
+}
+
+// FetchTextfileStats reads the *.prom files of a directory and adds their samples to the textfile group
+func FetchTextfileStats(statsMap map[string]*types.Series, dir string) error {
+	fileStats, err := custom.GetTextfileStats(dir)
+	if err != nil {
+		return err
+	}
+
+	fetchCustomStats(statsMap, "textfile", fileStats)
+	return nil
+}
+
+// fetchCustomStats adds user supplied samples to the stats map.
+// Samples of a source sharing the same tags are metrics of one entry.
+func fetchCustomStats(statsMap map[string]*types.Series, group string, sourceStats []custom.Stats) {
+	for _, stat := range sourceStats {
+		for _, sample := range stat.Samples {
+			tagKeys := make([]string, 0, len(sample.Tags))
+			for key := range sample.Tags {
+				tagKeys = append(tagKeys, key)
+			}
+			sort.Strings(tagKeys)
+
+			ID := group + ":" + stat.Source
+			for _, key := range tagKeys {
+				ID += "," + key + "=" + sample.Tags[key]
+			}
+
+			// Supplied tags such as name would clash with the tags of every line, so they are prefixed
+			tags := map[string]string{"source": stat.Source}
+			for key, value := range sample.Tags {
+				if key == "source" || grafana.IsReservedTag(key) {
+					key = "tag_" + key
+				}
+				tags[key] = value
+			}
+			existing := getSeries(statsMap, ID, stat.Source, group, tags)
+
+			updateMetric(existing, sample.Name, float32(sample.Value))
 		}
+	}
+}
+
+// unitStateMetrics maps the systemd active states to their metric, a unit in none of them is inactive
+var unitStateMetrics = map[string]string{
+	"active":       "unit_active",
+	"activating":   "unit_activating",
+	"deactivating": "unit_deactivating",
+	"failed":       "unit_failed",
+	"reloading":    "unit_reloading",
+}
+
+// unitSubStateMetrics maps the systemd sub states that tell more than the active state to their metric,
+// e.g. whether an active service still runs or a oneshot one exited, or whether an activating one waits to restart
+var unitSubStateMetrics = map[string]string{
+	"running":      "unit_sub_running",
+	"exited":       "unit_sub_exited",
+	"auto-restart": "unit_sub_auto_restart",
+}
+
+// FetchSystemdStats fetches and updates the usage and state of the systemd services matching the pattern
+func FetchSystemdStats(statsMap map[string]*types.Series, pattern *regexp.Regexp) error {
+	unitStats, err := systemd.GetStats(pattern)
+	if err != nil {
+		return err
+	}
+
+	for _, stat := range unitStats {
+		ID := stat.Unit
 
-		// Update Memory average
-		existing.MemMBPercSum += memMB
-		existing.MemCount++
-		existing.MemAvgMB = helpers.RoundToTwoDecimal(existing.MemMBPercSum / float32(existing.MemCount))
+		existing := getSeries(statsMap, ID, stat.Unit, "systemd", map[string]string{"unit": stat.Unit})
 
-		// Update Memory percentages
-		if workerStats.MemPerc < existing.MemMinPerc {
-			existing.MemMinPerc = workerStats.MemPerc
+		// Each state is a 0/1 metric, so a state held at any point within the flush window shows in its max
+		if stat.ActiveState != "" {
+			for state, name := range unitStateMetrics {
+				var value float32
+				if stat.ActiveState == state {
+					value = 1
+				}
+				updateMetric(existing, name, value)
+			}
+			for state, name := range unitSubStateMetrics {
+				var value float32
+				if stat.SubState == state {
+					value = 1
+				}
+				updateMetric(existing, name, value)
+			}
+			updateMetric(existing, "unit_restarts", float32(stat.Restarts))
 		}
-		if workerStats.MemPerc > existing.MemMaxPerc {
-			existing.MemMaxPerc = workerStats.MemPerc
+
+		if !stat.Running {
+			continue
 		}
 
-		// Update Memory percentage average
-		existing.MemPercSum += workerStats.MemPerc
-		existing.MemPercCount++
-		existing.MemAvgPerc = helpers.RoundToTwoDecimal(existing.MemPercSum / float32(existing.MemPercCount))
-	} else {
-		statsMap[ID] = &types.Stats{
-			ID:           ID,
-			Name:         NAME,
-			Group:        "worker",
-			CPUMinPerc:   workerStats.CPUPerc,
-			CPUMaxPerc:   workerStats.CPUPerc,
-			CPUPercSum:   workerStats.CPUPerc,
-			CPUCount:     1,
-			CPUAvgPerc:   workerStats.CPUPerc,
-			MemMinMB:     helpers.RoundToTwoDecimal(float32(workerStats.MemKB) / 1024),
-			MemMaxMB:     helpers.RoundToTwoDecimal(float32(workerStats.MemKB) / 1024),
-			MemMBPercSum: helpers.RoundToTwoDecimal(float32(workerStats.MemKB) / 1024),
-			MemCount:     1,
-			MemAvgMB:     helpers.RoundToTwoDecimal(float32(workerStats.MemKB) / 1024),
-			MemMinPerc:   workerStats.MemPerc,
-			MemMaxPerc:   workerStats.MemPerc,
-			MemPercSum:   workerStats.MemPerc,
-			MemPercCount: 1,
-			MemAvgPerc:   workerStats.MemPerc,
-			DiskMB:       0,
+		updateMetric(existing, "mem_mb", stat.MemMB)
+		updateMetric(existing, "unit_tasks", float32(stat.Tasks))
+
+		if stat.CPUReady {
+			updateMetric(existing, "cpu_perc", stat.CPUPerc)
+			updateMetric(existing, "unit_read_bytes_per_sec", stat.ReadBytesPerSec)
+			updateMetric(existing, "unit_write_bytes_per_sec", stat.WriteBytesPerSec)
 		}
 	}
 
 	return nil
 }
+
+// FetchWorkerStats fetches and updates worker stats
+func FetchWorkerStats(statsMap map[string]*types.Series, pidStr string, pid uint32, processName string) error {
+	workerStats, err := worker.GetStats(pidStr, pid)
+	if err != nil {
+		return err
+	}
+
+	ID := pidStr
+	NAME := processName
+
+	existing := getSeries(statsMap, ID, NAME, "worker", nil)
+	updateMetric(existing, "mem_mb", helpers.RoundToTwoDecimal(float32(workerStats.MemKB)/1024))
+	updateMetric(existing, "mem_perc", workerStats.MemPerc)
+
+	if workerStats.CPUReady {
+		updateMetric(existing, "cpu_perc", workerStats.CPUPerc)
+	}
+
+	return nil
+}
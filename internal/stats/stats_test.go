@@ -0,0 +1,35 @@
+// internal/stats/stats_test.go
+
+package stats
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/therceman/gomon/internal/stats/custom"
+	"github.com/therceman/gomon/internal/types"
+)
+
+func TestFetchCustomStats(t *testing.T) {
+	statsMap := make(map[string]*types.Series)
+	fetchCustomStats(statsMap, "exec", []custom.Stats{{
+		Source: "queue",
+		Samples: []custom.Sample{
+			{Name: "queue_depth", Tags: map[string]string{"name": "mail", "group": "a", "region": "eu"}, Value: 12},
+		},
+	}})
+
+	if len(statsMap) != 1 {
+		t.Fatalf("len(statsMap) = %d, expected 1", len(statsMap))
+	}
+	for _, series := range statsMap {
+		// Tags written on every line are prefixed, so the line does not hold them twice
+		expected := map[string]string{"source": "queue", "tag_name": "mail", "tag_group": "a", "region": "eu"}
+		if !reflect.DeepEqual(series.Tags, expected) {
+			t.Errorf("Tags = %v, expected %v", series.Tags, expected)
+		}
+		if series.Name != "queue" || series.Group != "exec" || series.Metrics["queue_depth"].Last != 12 {
+			t.Errorf("series = %+v, expected queue_depth of the queue source", series)
+		}
+	}
+}
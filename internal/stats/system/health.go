@@ -0,0 +1,84 @@
+// internal/stats/system/health.go
+
+package system
+
+import (
+	"log"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/hostfs"
+)
+
+// Clock status bits of adjtimex, see include/uapi/linux/timex.h
+const (
+	timeError  = 5      // Clock state returned while the clock is not synchronised
+	staUnsync  = 0x0040 // Clock is not synchronised
+	staNanoRes = 0x2000 // Offset is in nanoseconds rather than microseconds
+)
+
+type healthStats struct {
+	EntropyReady  bool    `json:"-"` // False when the entropy pool could not be read
+	EntropyAvail  uint32  `json:"entropy_avail"`
+	ClockReady    bool    `json:"-"` // False when adjtimex is not permitted, e.g. by a seccomp profile
+	ClockSynced   bool    `json:"clock_synced"`
+	ClockOffsetMs float32 `json:"clock_offset_ms"`
+	ClockErrorMs  float32 `json:"clock_error_ms"`
+	KernelVersion string  `json:"kernel_version"`
+	BootID        string  `json:"boot_id"`
+}
+
+// adjtimexFailing is set while adjtimex fails, so a denied syscall is only logged once rather than on every tick
+var adjtimexFailing bool
+
+// getHealthStats reads the available entropy, the clock synchronisation state and the kernel identity.
+// Each of them is optional, those that cannot be read are logged and left empty.
+func getHealthStats() healthStats {
+	var result healthStats
+
+	entropy, err := readUints(hostfs.Proc("sys", "kernel", "random", "entropy_avail"))
+	if err != nil {
+		log.Printf("Error reading available entropy: %v", err)
+	} else if len(entropy) > 0 {
+		result.EntropyReady = true
+		result.EntropyAvail = uint32(entropy[0])
+	}
+
+	if kernelVersion, err := os.ReadFile(hostfs.Proc("sys", "kernel", "osrelease")); err != nil {
+		log.Printf("Error reading kernel version: %v", err)
+	} else {
+		result.KernelVersion = strings.TrimSpace(string(kernelVersion))
+	}
+
+	if bootID, err := os.ReadFile(hostfs.Proc("sys", "kernel", "random", "boot_id")); err != nil {
+		log.Printf("Error reading boot id: %v", err)
+	} else {
+		result.BootID = strings.TrimSpace(string(bootID))
+	}
+
+	// The clock is shared with the host kernel, so it is read directly rather than through HOST_PROC
+	var timex syscall.Timex
+	state, err := syscall.Adjtimex(&timex)
+	if err != nil {
+		if !adjtimexFailing {
+			log.Printf("Error reading clock state with adjtimex, clock metrics are skipped: %v", err)
+			adjtimexFailing = true
+		}
+		return result
+	}
+	adjtimexFailing = false
+
+	offset := float32(timex.Offset) / 1000 // Convert us to ms
+	if timex.Status&staNanoRes != 0 {
+		offset = float32(timex.Offset) / 1000000 // Convert ns to ms
+	}
+
+	result.ClockReady = true
+	result.ClockSynced = state != timeError && timex.Status&staUnsync == 0
+	result.ClockOffsetMs = helpers.RoundToTwoDecimal(offset)
+	result.ClockErrorMs = helpers.RoundToTwoDecimal(float32(timex.Maxerror) / 1000) // Convert us to ms
+
+	return result
+}
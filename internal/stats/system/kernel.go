@@ -0,0 +1,232 @@
+// internal/stats/system/kernel.go
+
+package system
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/hostfs"
+	"github.com/therceman/gomon/internal/stats/counter"
+)
+
+type loadStats struct {
+	Load1  float32 `json:"load1"`
+	Load5  float32 `json:"load5"`
+	Load15 float32 `json:"load15"`
+}
+
+type kernelStats struct {
+	UptimeSec    float32 `json:"uptime_sec"`
+	ProcsRunning uint32  `json:"procs_running"`
+	ProcsBlocked uint32  `json:"procs_blocked"`
+	RatesReady   bool    `json:"-"`
+	CtxtPerSec   float32 `json:"ctxt_per_sec"`
+	IntrPerSec   float32 `json:"intr_per_sec"`
+	ForksPerSec  float32 `json:"forks_per_sec"`
+}
+
+type limitStats struct {
+	Ready       bool    `json:"-"`
+	OpenFDs     uint64  `json:"open_fds"`
+	OpenFDsPerc float32 `json:"open_fds_perc"`
+	Threads     uint64  `json:"threads"`
+	ThreadsPerc float32 `json:"threads_perc"`
+}
+
+// kernelSample holds the /proc/stat counters of a reading
+type kernelSample struct {
+	ctxt      uint64
+	intr      uint64
+	processes uint64
+}
+
+// Counters of /proc/stat converted to rates, the number of forks is an unsigned long unlike the others
+var (
+	ctxtCounter      = counter.New(64)
+	intrCounter      = counter.New(64)
+	processesCounter = counter.New(counter.NativeBits)
+)
+
+// getLoadStats parses the 1, 5 and 15 minute load averages from the lines of /proc/loadavg
+func getLoadStats(loadavg [][]string) (loadStats, error) {
+	if len(loadavg) < 1 || len(loadavg[0]) < 3 {
+		return loadStats{}, fmt.Errorf("unexpected format in /proc/loadavg")
+	}
+
+	var loads [3]float32
+	for i := range loads {
+		var err error
+		loads[i], err = helpers.ConvertStringToFloat32(loadavg[0][i])
+		if err != nil {
+			return loadStats{}, err
+		}
+	}
+
+	return loadStats{Load1: loads[0], Load5: loads[1], Load15: loads[2]}, nil
+}
+
+// getKernelStats reads uptime and parses process counts and context switch, interrupt and fork rates
+// from the lines of /proc/stat
+func getKernelStats(procStat [][]string) (kernelStats, error) {
+	uptime, err := getUptime()
+	if err != nil {
+		return kernelStats{}, err
+	}
+
+	result := kernelStats{UptimeSec: uptime}
+
+	var sample kernelSample
+	for _, fields := range procStat {
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "ctxt", "intr", "processes", "procs_running", "procs_blocked":
+		default:
+			continue
+		}
+
+		// The intr line is followed by per-interrupt counters, only the total is needed
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return kernelStats{}, err
+		}
+
+		switch fields[0] {
+		case "ctxt":
+			sample.ctxt = value
+		case "intr":
+			sample.intr = value
+		case "processes":
+			sample.processes = value
+		case "procs_running":
+			result.ProcsRunning = uint32(value)
+		case "procs_blocked":
+			result.ProcsBlocked = uint32(value)
+		}
+	}
+
+	now := time.Now()
+	_, ctxtPerSec, ctxtOK := ctxtCounter.Update(sample.ctxt, now)
+	_, intrPerSec, intrOK := intrCounter.Update(sample.intr, now)
+	_, forksPerSec, forksOK := processesCounter.Update(sample.processes, now)
+	if !ctxtOK || !intrOK || !forksOK {
+		return result, nil
+	}
+
+	result.RatesReady = true
+	result.CtxtPerSec = helpers.RoundToTwoDecimal(ctxtPerSec)
+	result.IntrPerSec = helpers.RoundToTwoDecimal(intrPerSec)
+	result.ForksPerSec = helpers.RoundToTwoDecimal(forksPerSec)
+
+	return result, nil
+}
+
+// getLimitStats reads system-wide file descriptor and thread usage against their kernel limits,
+// the number of threads is parsed from the lines of /proc/loadavg
+func getLimitStats(loadavg [][]string) (limitStats, error) {
+	// file-nr holds allocated, unused (always 0 on recent kernels) and maximum file handles
+	fileNr, err := readUints(hostfs.Proc("sys", "fs", "file-nr"))
+	if err != nil {
+		return limitStats{}, err
+	}
+	if len(fileNr) < 3 {
+		return limitStats{}, fmt.Errorf("unexpected format in file-nr")
+	}
+
+	threadsMax, err := readUints(hostfs.Proc("sys", "kernel", "threads-max"))
+	if err != nil {
+		return limitStats{}, err
+	}
+	if len(threadsMax) < 1 {
+		return limitStats{}, fmt.Errorf("unexpected format in threads-max")
+	}
+
+	// The fourth field of loadavg is running/total scheduling entities, i.e. threads
+	if len(loadavg) < 1 || len(loadavg[0]) < 4 {
+		return limitStats{}, fmt.Errorf("unexpected format in loadavg")
+	}
+	_, total, _ := strings.Cut(loadavg[0][3], "/")
+	threads, err := strconv.ParseUint(total, 10, 64)
+	if err != nil {
+		return limitStats{}, err
+	}
+
+	result := limitStats{
+		Ready:   true,
+		OpenFDs: fileNr[0] - fileNr[1],
+		Threads: threads,
+	}
+	if fileNr[2] > 0 {
+		result.OpenFDsPerc = helpers.RoundToTwoDecimal(float32(result.OpenFDs) / float32(fileNr[2]) * 100)
+	}
+	if threadsMax[0] > 0 {
+		result.ThreadsPerc = helpers.RoundToTwoDecimal(float32(threads) / float32(threadsMax[0]) * 100)
+	}
+
+	return result, nil
+}
+
+// readFields reads a file into the whitespace separated fields of each line, such as /proc/stat
+func readFields(path string) (lines [][]string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, strings.Fields(scanner.Text()))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	return lines, nil
+}
+
+// readUints reads a file holding whitespace separated numbers, such as the files below /proc/sys
+func readUints(path string) ([]uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []uint64
+	for _, field := range strings.Fields(string(data)) {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// getUptime reads the system uptime in seconds from /proc/uptime
+func getUptime() (float32, error) {
+	data, err := os.ReadFile(hostfs.Proc("uptime"))
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 1 {
+		return 0, fmt.Errorf("unexpected format in /proc/uptime")
+	}
+
+	return helpers.ConvertStringToFloat32(fields[0])
+}
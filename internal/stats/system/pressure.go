@@ -0,0 +1,23 @@
+// internal/stats/system/pressure.go
+
+package system
+
+import (
+	"github.com/therceman/gomon/internal/hostfs"
+	"github.com/therceman/gomon/internal/psi"
+	"github.com/therceman/gomon/internal/stats/counter"
+)
+
+// pressureCounters holds the stall totals of the host, which are u64 in the kernel
+var pressureCounters = counter.NewSet(64)
+
+// getPressureStats reads the host cpu, memory and io pressure from <proc>/pressure
+func getPressureStats() (map[string]float32, error) {
+	files := map[string]string{
+		"cpu":    hostfs.Proc("pressure", "cpu"),
+		"memory": hostfs.Proc("pressure", "memory"),
+		"io":     hostfs.Proc("pressure", "io"),
+	}
+
+	return psi.Collect(files, pressureCounters, "host")
+}
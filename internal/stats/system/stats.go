@@ -4,7 +4,7 @@ package system
 
 import (
 	"bufio"
-	"fmt"
+	"log"
 	"os"
 	"strconv"
 	"strings"
@@ -12,50 +12,249 @@ import (
 	"time"
 
 	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/hostfs"
+	"github.com/therceman/gomon/internal/stats/counter"
 )
 
 // Stats holds combined metrics for system resources
 type Stats struct {
-	MemMB    uint32  `json:"mem"`       // Used memory in MB
-	MemPerc  float32 `json:"mem_perc"`  // Used memory percentage
-	CPUPerc  float32 `json:"cpu_perc"`  // CPU usage percentage
-	DiskMB   uint32  `json:"disk_mb"`   // Used disk space in MB
-	DiskPerc float32 `json:"disk_perc"` // Used disk space percentage
+	MemMB          uint32  `json:"mem"`              // Used memory in MB
+	MemPerc        float32 `json:"mem_perc"`         // Used memory percentage
+	MemBuffersMB   float32 `json:"mem_buffers_mb"`   // Memory used by block device buffers in MB
+	MemCachedMB    float32 `json:"mem_cached_mb"`    // Memory used by the page cache in MB
+	MemSharedMB    float32 `json:"mem_shared_mb"`    // Shared memory and tmpfs in MB
+	MemSlabMB      float32 `json:"mem_slab_mb"`      // Kernel slab memory in MB
+	MemDirtyMB     float32 `json:"mem_dirty_mb"`     // Memory waiting to be written back to disk in MB
+	SwapTotalMB    float32 `json:"swap_total_mb"`    // Total swap space in MB
+	SwapUsedMB     float32 `json:"swap_used_mb"`     // Used swap space in MB
+	SwapUsedPerc   float32 `json:"swap_used_perc"`   // Used swap space percentage
+	VMReady        bool    `json:"-"`                // False until a previous /proc/vmstat sample is available
+	PgpginPerSec   float32 `json:"pgpgin_per_sec"`   // KB paged in from disk per second
+	PgpgoutPerSec  float32 `json:"pgpgout_per_sec"`  // KB paged out to disk per second
+	PswpinPerSec   float32 `json:"pswpin_per_sec"`   // Pages swapped in per second
+	PswpoutPerSec  float32 `json:"pswpout_per_sec"`  // Pages swapped out per second
+	MajFaultPerSec float32 `json:"majfault_per_sec"` // Major page faults per second
+	CPUReady       bool    `json:"-"`                // False until a previous CPU sample is available
+	CPUPerc        float32 `json:"cpu_perc"`         // CPU usage percentage
+	CPUUserPerc    float32 `json:"cpu_user_perc"`    // CPU time spent in user mode
+	CPUSystemPerc  float32 `json:"cpu_system_perc"`  // CPU time spent in kernel mode
+	CPUIOWaitPerc  float32 `json:"cpu_iowait_perc"`  // CPU time spent waiting for I/O
+	CPUStealPerc   float32 `json:"cpu_steal_perc"`   // CPU time stolen by the hypervisor
+	CPUIRQPerc     float32 `json:"cpu_irq_perc"`     // CPU time spent servicing interrupts
+	CPUSoftIRQPerc float32 `json:"cpu_softirq_perc"` // CPU time spent servicing softirqs
+	DiskMB         uint32  `json:"disk_mb"`          // Used disk space in MB
+	DiskPerc       float32 `json:"disk_perc"`        // Used disk space percentage
+	Load1          float32 `json:"load1"`            // 1 minute load average
+	Load5          float32 `json:"load5"`            // 5 minute load average
+	Load15         float32 `json:"load15"`           // 15 minute load average
+	UptimeSec      float32 `json:"uptime_sec"`       // Time since boot in seconds
+	ProcsRunning   uint32  `json:"procs_running"`    // Processes in runnable state
+	ProcsBlocked   uint32  `json:"procs_blocked"`    // Processes blocked on I/O
+	RatesReady     bool    `json:"-"`                // False until a previous counter sample is available
+	CtxtPerSec     float32 `json:"ctxt_per_sec"`     // Context switches per second
+	IntrPerSec     float32 `json:"intr_per_sec"`     // Interrupts per second
+	ForksPerSec    float32 `json:"forks_per_sec"`    // Forks per second
+	LimitsReady    bool    `json:"-"`                // False when the file descriptor and thread limits could not be read
+	OpenFDs        uint64  `json:"open_fds"`         // Allocated file handles
+	OpenFDsPerc    float32 `json:"open_fds_perc"`    // Allocated file handles percentage of fs.file-max
+	Threads        uint64  `json:"threads"`          // Number of threads
+	ThreadsPerc    float32 `json:"threads_perc"`     // Number of threads percentage of kernel.threads-max
+	EntropyReady   bool    `json:"-"`                // False when the entropy pool could not be read
+	EntropyAvail   uint32  `json:"entropy_avail"`    // Available entropy of the kernel random pool in bits
+	ClockReady     bool    `json:"-"`                // False when the clock state could not be read
+	ClockSynced    bool    `json:"clock_synced"`     // Whether the kernel clock is synchronised, e.g. by NTP
+	ClockOffsetMs  float32 `json:"clock_offset_ms"`  // Estimated clock offset in milliseconds
+	ClockErrorMs   float32 `json:"clock_error_ms"`   // Maximum clock error in milliseconds
+	KernelVersion  string  `json:"kernel_version"`   // Kernel release, e.g. 6.8.0-45-generic
+	BootID         string  `json:"boot_id"`          // Random ID generated on every boot
+	// CPU usage percentage per core keyed by name, e.g. cpu0
+	CoresPerc map[string]float32 `json:"cores_perc"`
+	// Pressure stall metrics keyed by name, e.g. psi_cpu_some_avg10_perc
+	PSI map[string]float32 `json:"psi"`
 }
 
-// GetStats retrieves system statistics including memory, CPUPerc, and disk usage
+// GetStats retrieves system statistics including memory, CPUPerc, disk usage, load and kernel activity
 func GetStats() (Stats, error) {
 	memStats, err := getMemStats()
 	if err != nil {
 		return Stats{}, err
 	}
 
-	cpuStats, err := getCPUStats()
+	// Paging rates are optional, they are skipped when /proc/vmstat cannot be read
+	vmStats, err := getVMStats()
+	if err != nil {
+		log.Printf("Error reading paging stats: %v", err)
+	}
+
+	// /proc/stat and /proc/loadavg are shared by several helpers, so each is only read once per call
+	procStat, err := readFields(hostfs.Proc("stat"))
+	if err != nil {
+		return Stats{}, err
+	}
+
+	loadavg, err := readFields(hostfs.Proc("loadavg"))
+	if err != nil {
+		return Stats{}, err
+	}
+
+	cpuStats, err := getCPUStats(procStat)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	diskStats, err := getDiskStats(hostfs.RootDir)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	loadStats, err := getLoadStats(loadavg)
 	if err != nil {
 		return Stats{}, err
 	}
 
-	diskStats, err := getDiskStats("/")
+	kernelStats, err := getKernelStats(procStat)
 	if err != nil {
 		return Stats{}, err
 	}
 
+	// Limits, health and pressure are optional, so an unreadable file does not cost the baseline stats
+	limitStats, err := getLimitStats(loadavg)
+	if err != nil {
+		log.Printf("Error reading file descriptor and thread limits: %v", err)
+	}
+
+	healthStats := getHealthStats()
+
+	pressureStats, err := getPressureStats()
+	if err != nil {
+		log.Printf("Error reading pressure stats: %v", err)
+	}
+
 	return Stats{
-		MemMB:    memStats.Used,
-		MemPerc:  memStats.UsedPercent,
-		CPUPerc:  cpuStats.CPUUsagePercent,
-		DiskMB:   diskStats.Used,
-		DiskPerc: diskStats.UsedPerc,
+		MemMB:          memStats.Used,
+		MemPerc:        memStats.UsedPercent,
+		MemBuffersMB:   memStats.BuffersMB,
+		MemCachedMB:    memStats.CachedMB,
+		MemSharedMB:    memStats.SharedMB,
+		MemSlabMB:      memStats.SlabMB,
+		MemDirtyMB:     memStats.DirtyMB,
+		SwapTotalMB:    memStats.SwapTotalMB,
+		SwapUsedMB:     memStats.SwapUsedMB,
+		SwapUsedPerc:   memStats.SwapUsedPercent,
+		VMReady:        vmStats.Ready,
+		PgpginPerSec:   vmStats.PgpginPerSec,
+		PgpgoutPerSec:  vmStats.PgpgoutPerSec,
+		PswpinPerSec:   vmStats.PswpinPerSec,
+		PswpoutPerSec:  vmStats.PswpoutPerSec,
+		MajFaultPerSec: vmStats.MajFaultPerSec,
+		CPUReady:       cpuStats.Ready,
+		CPUPerc:        cpuStats.CPUUsagePercent,
+		CPUUserPerc:    cpuStats.UserPercent,
+		CPUSystemPerc:  cpuStats.SystemPercent,
+		CPUIOWaitPerc:  cpuStats.IOWaitPercent,
+		CPUStealPerc:   cpuStats.StealPercent,
+		CPUIRQPerc:     cpuStats.IRQPercent,
+		CPUSoftIRQPerc: cpuStats.SoftIRQPercent,
+		CoresPerc:      cpuStats.CoresPercent,
+		DiskMB:         diskStats.Used,
+		DiskPerc:       diskStats.UsedPerc,
+		Load1:          loadStats.Load1,
+		Load5:          loadStats.Load5,
+		Load15:         loadStats.Load15,
+		UptimeSec:      kernelStats.UptimeSec,
+		ProcsRunning:   kernelStats.ProcsRunning,
+		ProcsBlocked:   kernelStats.ProcsBlocked,
+		RatesReady:     kernelStats.RatesReady,
+		CtxtPerSec:     kernelStats.CtxtPerSec,
+		IntrPerSec:     kernelStats.IntrPerSec,
+		ForksPerSec:    kernelStats.ForksPerSec,
+		LimitsReady:    limitStats.Ready,
+		OpenFDs:        limitStats.OpenFDs,
+		OpenFDsPerc:    limitStats.OpenFDsPerc,
+		Threads:        limitStats.Threads,
+		ThreadsPerc:    limitStats.ThreadsPerc,
+		EntropyReady:   healthStats.EntropyReady,
+		EntropyAvail:   healthStats.EntropyAvail,
+		ClockReady:     healthStats.ClockReady,
+		ClockSynced:    healthStats.ClockSynced,
+		ClockOffsetMs:  healthStats.ClockOffsetMs,
+		ClockErrorMs:   healthStats.ClockErrorMs,
+		KernelVersion:  healthStats.KernelVersion,
+		BootID:         healthStats.BootID,
+		PSI:            pressureStats,
 	}, nil
 }
 
 type memStats struct {
-	Used        uint32  `json:"used"`
-	UsedPercent float32 `json:"used_percent"`
+	Used            uint32  `json:"used"`
+	UsedPercent     float32 `json:"used_percent"`
+	BuffersMB       float32 `json:"buffers_mb"`
+	CachedMB        float32 `json:"cached_mb"`
+	SharedMB        float32 `json:"shared_mb"`
+	SlabMB          float32 `json:"slab_mb"`
+	DirtyMB         float32 `json:"dirty_mb"`
+	SwapTotalMB     float32 `json:"swap_total_mb"`
+	SwapUsedMB      float32 `json:"swap_used_mb"`
+	SwapUsedPercent float32 `json:"swap_used_percent"`
 }
 
 type cpuStats struct {
+	Ready           bool    `json:"-"`
 	CPUUsagePercent float32 `json:"cpu_usage_percent"`
+	UserPercent     float32 `json:"user_percent"`
+	SystemPercent   float32 `json:"system_percent"`
+	IOWaitPercent   float32 `json:"iowait_percent"`
+	StealPercent    float32 `json:"steal_percent"`
+	IRQPercent      float32 `json:"irq_percent"`
+	SoftIRQPercent  float32 `json:"softirq_percent"`
+	// CPU usage percentage per core keyed by name
+	CoresPercent map[string]float32 `json:"cores_percent"`
+}
+
+// cpuTimes holds the ticks spent in each CPU mode as reported by /proc/stat
+type cpuTimes struct {
+	User    uint64
+	Nice    uint64
+	System  uint64
+	Idle    uint64
+	IOWait  uint64
+	IRQ     uint64
+	SoftIRQ uint64
+	Steal   uint64
+}
+
+// total returns the sum of ticks over all modes.
+// Guest time is already accounted in user and nice, so it is not added again.
+func (t cpuTimes) total() uint64 {
+	return t.User + t.Nice + t.System + t.Idle + t.IOWait + t.IRQ + t.SoftIRQ + t.Steal
+}
+
+// cpuCounters holds the /proc/stat ticks per CPU and mode. Idle and iowait ticks can go back (see proc(5)),
+// which is taken as no time spent in the mode rather than a wraparound or reset.
+var cpuCounters = counter.NewClampedSet(64)
+
+// cpuTicks returns the ticks spent in each mode of the CPU since the previous call,
+// or false when there is no previous reading, e.g. for a core that just went online
+func cpuTicks(name string, times cpuTimes, at time.Time) (cpuTimes, bool) {
+	ready := true
+	update := func(mode string, value uint64) uint64 {
+		increase, _, ok := cpuCounters.Update(name+"/"+mode, value, at)
+		ready = ready && ok
+		return increase
+	}
+
+	ticks := cpuTimes{
+		User:    update("user", times.User),
+		Nice:    update("nice", times.Nice),
+		System:  update("system", times.System),
+		Idle:    update("idle", times.Idle),
+		IOWait:  update("iowait", times.IOWait),
+		IRQ:     update("irq", times.IRQ),
+		SoftIRQ: update("softirq", times.SoftIRQ),
+		Steal:   update("steal", times.Steal),
+	}
+
+	return ticks, ready
 }
 
 type diskStats struct {
@@ -66,7 +265,7 @@ type diskStats struct {
 func getMemStats() (memStats, error) {
 	var result memStats
 
-	file, err := os.Open("/proc/meminfo")
+	file, err := os.Open(hostfs.Proc("meminfo"))
 	if err != nil {
 		return result, err
 	}
@@ -77,112 +276,155 @@ func getMemStats() (memStats, error) {
 		}
 	}()
 
-	var total, free uint64
+	// Values of /proc/meminfo in KB, keyed by name
+	memInfo := make(map[string]uint64)
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "MemTotal:") {
-			fields := strings.Fields(line)
-			total, err = strconv.ParseUint(fields[1], 10, 64)
-			if err != nil {
-				return result, err
-			}
-		} else if strings.HasPrefix(line, "MemAvailable:") {
-			fields := strings.Fields(line)
-			free, err = strconv.ParseUint(fields[1], 10, 64)
-			if err != nil {
-				return result, err
-			}
-			break
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return result, err
 		}
+		memInfo[strings.TrimSuffix(fields[0], ":")] = value
 	}
 
 	if err := scanner.Err(); err != nil {
 		return result, err
 	}
 
-	used := total - free
+	total := memInfo["MemTotal"]
+	used := total - memInfo["MemAvailable"]
 	usedPercent := (float32(used) / float32(total)) * 100
 
+	swapUsed := memInfo["SwapTotal"] - memInfo["SwapFree"]
+	var swapUsedPercent float32
+	if memInfo["SwapTotal"] > 0 {
+		swapUsedPercent = (float32(swapUsed) / float32(memInfo["SwapTotal"])) * 100
+	}
+
+	// Convert KB to MB
+	toMB := func(kb uint64) float32 {
+		return helpers.RoundToTwoDecimal(float32(kb) / 1024)
+	}
+
 	return memStats{
-		Used:        uint32(used / 1024), // Convert KB to MB
-		UsedPercent: helpers.RoundToTwoDecimal(usedPercent),
+		Used:            uint32(used / 1024), // Convert KB to MB
+		UsedPercent:     helpers.RoundToTwoDecimal(usedPercent),
+		BuffersMB:       toMB(memInfo["Buffers"]),
+		CachedMB:        toMB(memInfo["Cached"]),
+		SharedMB:        toMB(memInfo["Shmem"]),
+		SlabMB:          toMB(memInfo["Slab"]),
+		DirtyMB:         toMB(memInfo["Dirty"]),
+		SwapTotalMB:     toMB(memInfo["SwapTotal"]),
+		SwapUsedMB:      toMB(swapUsed),
+		SwapUsedPercent: helpers.RoundToTwoDecimal(swapUsedPercent),
 	}, nil
 }
 
-// getCPUStats retrieves CPUPerc usage percentage
-func getCPUStats() (cpuStats, error) {
-	idle0, total0, _, _, err := getCPUSample()
+// getCPUStats retrieves CPUPerc usage percentage, the breakdown per CPU mode and per-core usage
+// since the previous call from the lines of /proc/stat
+func getCPUStats(procStat [][]string) (cpuStats, error) {
+	total, cores, err := getCPUSample(procStat)
 	if err != nil {
 		return cpuStats{}, err
 	}
+	now := time.Now()
 
-	// Sleep for a short duration to get a new sample
-	time.Sleep(200 * time.Millisecond)
+	// Cores can go online or offline between readings, only those with a previous reading are compared
+	coresTicks := make(map[string]cpuTimes, len(cores))
+	for name, times := range cores {
+		if ticks, ok := cpuTicks(name, times, now); ok {
+			coresTicks[name] = ticks
+		}
+	}
+	ticks, ok := cpuTicks("cpu", total, now)
+	cpuCounters.Prune()
 
-	idle1, total1, _, _, err := getCPUSample()
-	if err != nil {
-		return cpuStats{}, err
+	if !ok {
+		return cpuStats{}, nil
 	}
 
-	idleTicks := float32(idle1 - idle0)
-	totalTicks := float32(total1 - total0)
+	totalTicks := float32(ticks.total())
 
 	// Avoid division by zero
 	if totalTicks == 0 {
-		return cpuStats{CPUUsagePercent: 0}, nil
+		return cpuStats{Ready: true}, nil
 	}
 
-	cpuUsage := (1.0 - (idleTicks / totalTicks)) * 100
+	modePerc := func(modeTicks uint64) float32 {
+		return helpers.RoundToTwoDecimal(float32(modeTicks) / totalTicks * 100)
+	}
 
-	return cpuStats{
-		CPUUsagePercent: helpers.RoundToTwoDecimal(cpuUsage),
-	}, nil
+	result := cpuStats{
+		Ready:           true,
+		CPUUsagePercent: getCPUUsagePercent(ticks),
+		UserPercent:     modePerc(ticks.User + ticks.Nice),
+		SystemPercent:   modePerc(ticks.System),
+		IOWaitPercent:   modePerc(ticks.IOWait),
+		StealPercent:    modePerc(ticks.Steal),
+		IRQPercent:      modePerc(ticks.IRQ),
+		SoftIRQPercent:  modePerc(ticks.SoftIRQ),
+		CoresPercent:    make(map[string]float32, len(coresTicks)),
+	}
+
+	for name, coreTicks := range coresTicks {
+		result.CoresPercent[name] = getCPUUsagePercent(coreTicks)
+	}
+
+	return result, nil
 }
 
-// getCPUSample reads CPUPerc usage statistics from /proc/stat
-// and returns per-core idle and total times
-func getCPUSample() (idle, total uint64, idleTimes, totalTimes []uint64, err error) {
-	file, err := os.Open("/proc/stat")
-	if err != nil {
-		return 0, 0, nil, nil, err
+// getCPUUsagePercent calculates the busy percentage of the ticks spent between two readings
+func getCPUUsagePercent(ticks cpuTimes) float32 {
+	totalTicks := float32(ticks.total())
+
+	// Avoid division by zero
+	if totalTicks == 0 {
+		return 0
 	}
-	defer func() {
-		if closeErr := file.Close(); closeErr != nil && err == nil {
-			err = closeErr
-		}
-	}()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		fields := strings.Fields(scanner.Text())
+	return helpers.RoundToTwoDecimal((1.0 - (float32(ticks.Idle) / totalTicks)) * 100)
+}
+
+// getCPUSample parses CPUPerc usage statistics from the lines of /proc/stat
+// and returns the aggregated and per-core times, keyed by core name
+func getCPUSample(procStat [][]string) (total cpuTimes, cores map[string]cpuTimes, err error) {
+	cores = make(map[string]cpuTimes)
+	for _, fields := range procStat {
 		if len(fields) > 0 && strings.HasPrefix(fields[0], "cpu") {
-			idleTime, totalTime := uint64(0), uint64(0)
+			var values [8]uint64
 			for i, v := range fields[1:] {
+				if i >= len(values) {
+					break // guest and guest_nice are already part of user and nice
+				}
 				val, err := strconv.ParseUint(v, 10, 64)
 				if err != nil {
-					return 0, 0, nil, nil, err
-				}
-				totalTime += val
-				if i == 3 { // idle time is the 4th field
-					idleTime = val
+					return cpuTimes{}, nil, err
 				}
+				values[i] = val
+			}
+			times := cpuTimes{
+				User:    values[0],
+				Nice:    values[1],
+				System:  values[2],
+				Idle:    values[3],
+				IOWait:  values[4],
+				IRQ:     values[5],
+				SoftIRQ: values[6],
+				Steal:   values[7],
 			}
 			if fields[0] == "cpu" {
-				total += totalTime
-				idle += idleTime
+				total = times
 			} else {
-				idleTimes = append(idleTimes, idleTime)
-				totalTimes = append(totalTimes, totalTime)
+				cores[fields[0]] = times
 			}
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return 0, 0, nil, nil, fmt.Errorf("error reading /proc/stat: %v", err)
-	}
-
-	return idle, total, idleTimes, totalTimes, nil
+	return total, cores, nil
 }
 
 func getDiskStats(path string) (diskStats, error) {
@@ -194,8 +436,11 @@ func getDiskStats(path string) (diskStats, error) {
 
 	total := stat.Blocks * uint64(stat.Bsize)
 	free := stat.Bfree * uint64(stat.Bsize)
+	avail := stat.Bavail * uint64(stat.Bsize)
 	used := total - free
-	usedPerc := (float32(used) / float32(total)) * 100
+
+	// Like df, blocks reserved for root are neither used nor available
+	usedPerc := (float32(used) / float32(used+avail)) * 100
 
 	return diskStats{
 		Used:     uint32(used / 1024 / 1024), // Convert KB to MB
@@ -0,0 +1,88 @@
+// internal/stats/system/vmstat.go
+
+package system
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/hostfs"
+	"github.com/therceman/gomon/internal/stats/counter"
+)
+
+type vmStats struct {
+	Ready          bool    `json:"-"`
+	PgpginPerSec   float32 `json:"pgpgin_per_sec"`
+	PgpgoutPerSec  float32 `json:"pgpgout_per_sec"`
+	PswpinPerSec   float32 `json:"pswpin_per_sec"`
+	PswpoutPerSec  float32 `json:"pswpout_per_sec"`
+	MajFaultPerSec float32 `json:"majfault_per_sec"`
+}
+
+// vmCounterNames are the /proc/vmstat counters converted to rates
+var vmCounterNames = []string{"pgpgin", "pgpgout", "pswpin", "pswpout", "pgmajfault"}
+
+// vmCounters holds the /proc/vmstat counters by name
+var vmCounters = counter.NewSet(counter.NativeBits)
+
+// getVMStats calculates paging, swapping and major fault rates since the previous call
+func getVMStats() (vmStats, error) {
+	path := hostfs.Proc("vmstat")
+	file, err := os.Open(path)
+	if err != nil {
+		return vmStats{}, err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		for _, name := range vmCounterNames {
+			if fields[0] == name {
+				value, err := strconv.ParseUint(fields[1], 10, 64)
+				if err != nil {
+					return vmStats{}, err
+				}
+				values[name] = value
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return vmStats{}, fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	now := time.Now()
+	ready := true
+	rates := make(map[string]float32, len(vmCounterNames))
+	for _, name := range vmCounterNames {
+		_, rate, ok := vmCounters.Update(name, values[name], now)
+		ready = ready && ok
+		rates[name] = helpers.RoundToTwoDecimal(rate)
+	}
+	if !ready {
+		return vmStats{}, nil
+	}
+
+	return vmStats{
+		Ready:          true,
+		PgpginPerSec:   rates["pgpgin"],
+		PgpgoutPerSec:  rates["pgpgout"],
+		PswpinPerSec:   rates["pswpin"],
+		PswpoutPerSec:  rates["pswpout"],
+		MajFaultPerSec: rates["pgmajfault"],
+	}, nil
+}
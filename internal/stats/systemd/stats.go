@@ -0,0 +1,272 @@
+// internal/stats/systemd/stats.go
+
+package systemd
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/therceman/gomon/internal/cgroup"
+	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/stats/counter"
+)
+
+// Stats holds the resource usage and state of a systemd service
+type Stats struct {
+	Unit             string  `json:"unit"`                // Unit name, e.g. nginx.service
+	Running          bool    `json:"running"`             // Whether the unit has a cgroup, i.e. has processes
+	CPUReady         bool    `json:"-"`                   // False until a previous sample is available
+	CPUPerc          float32 `json:"cpu_perc"`            // CPU usage percentage, 100% is one core
+	MemMB            float32 `json:"mem_mb"`              // Memory used by the cgroup in MB
+	Tasks            uint32  `json:"tasks"`               // Number of tasks in the cgroup
+	ReadBytesPerSec  float32 `json:"read_bytes_per_sec"`  // Bytes read per second
+	WriteBytesPerSec float32 `json:"write_bytes_per_sec"` // Bytes written per second
+	ActiveState      string  `json:"active_state"`        // e.g. active, failed, activating
+	SubState         string  `json:"sub_state"`           // e.g. running, exited, auto-restart
+	Restarts         uint32  `json:"restarts"`            // Number of automatic restarts
+}
+
+var (
+	// counters holds the cgroup CPU and I/O counters per running unit and field
+	counters = counter.NewSet(64)
+	// knownUnits keeps units that were seen running, so their state is still reported once they stopped or failed.
+	// Units that stopped cleanly are forgotten once their inactive state was reported.
+	knownUnits = make(map[string]bool)
+	// unitsListed is set once knownUnits was seeded with the units systemd knows, including those failed before start
+	unitsListed bool
+)
+
+// GetStats retrieves the usage of the services in system.slice whose unit name matches the pattern
+func GetStats(pattern *regexp.Regexp) ([]Stats, error) {
+	groups, err := cgroup.FindServices()
+	if err != nil {
+		return nil, err
+	}
+
+	if !unitsListed {
+		unitsListed = true
+		if err := listUnits(pattern); err != nil {
+			log.Printf("Error listing systemd units: %v", err)
+		}
+	}
+
+	statsByUnit := make(map[string]*Stats)
+	for _, group := range groups {
+		unit := filepath.Base(group)
+		if pattern != nil && !pattern.MatchString(unit) {
+			continue
+		}
+		knownUnits[unit] = true
+
+		stat, err := getUnitStats(unit, group)
+		if err != nil {
+			// The unit may stop while it is read
+			log.Printf("Error reading cgroup of %s: %v", unit, err)
+			continue
+		}
+		statsByUnit[unit] = &stat
+	}
+	counters.Prune()
+
+	var units []string
+	for unit := range knownUnits {
+		units = append(units, unit)
+		if _, found := statsByUnit[unit]; !found {
+			statsByUnit[unit] = &Stats{Unit: unit}
+		}
+	}
+	sort.Strings(units)
+
+	// States come from systemd itself, gomon may run where systemctl is not available
+	states, err := getUnitStates(units)
+	if err != nil {
+		log.Printf("Error reading systemd unit states: %v", err)
+	}
+	for unit, state := range states {
+		stat, found := statsByUnit[unit]
+		if !found {
+			continue
+		}
+		stat.ActiveState = state.ActiveState
+		stat.SubState = state.SubState
+		stat.Restarts = state.Restarts
+
+		// Failed units are kept until they are reset or started again
+		if !stat.Running && state.ActiveState == "inactive" {
+			delete(knownUnits, unit)
+		}
+	}
+
+	var stats []Stats
+	for _, unit := range units {
+		stats = append(stats, *statsByUnit[unit])
+	}
+	return stats, nil
+}
+
+// getUnitStats reads CPU, memory, I/O and task usage from the cgroup of a unit
+func getUnitStats(unit string, group string) (Stats, error) {
+	cpuUsage, err := cgroup.ReadCPUUsage(group)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	memUsage, err := cgroup.ReadMemoryUsage(group)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	tasks, err := cgroup.ReadPIDsCurrent(group)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	// I/O accounting may be disabled for the unit
+	readBytes, writeBytes, _ := cgroup.ReadIOBytes(group)
+
+	stat := Stats{
+		Unit:    unit,
+		Running: true,
+		MemMB:   helpers.RoundToTwoDecimal(float32(memUsage) / 1024 / 1024), // Convert bytes to MB
+		Tasks:   uint32(tasks),
+	}
+
+	// A restarted unit gets a new cgroup with counters starting from zero, which the counters take as a reset
+	now := time.Now()
+	_, cpuUsecPerSec, cpuOK := counters.Update(unit+"/cpu_usage", cpuUsage, now)
+	_, readBytesPerSec, readOK := counters.Update(unit+"/read_bytes", readBytes, now)
+	_, writeBytesPerSec, writeOK := counters.Update(unit+"/write_bytes", writeBytes, now)
+	if cpuOK && readOK && writeOK {
+		stat.CPUReady = true
+		stat.CPUPerc = helpers.RoundToTwoDecimal(cpuUsecPerSec / 1e6 * 100)
+		stat.ReadBytesPerSec = helpers.RoundToTwoDecimal(readBytesPerSec)
+		stat.WriteBytesPerSec = helpers.RoundToTwoDecimal(writeBytesPerSec)
+	}
+
+	return stat, nil
+}
+
+// listUnits adds the services known to systemd whose name matches the pattern to knownUnits,
+// so units that failed before gomon started are reported as well
+func listUnits(pattern *regexp.Regexp) error {
+	cmd := exec.Command("systemctl", "list-units", "--all", "--type=service", "--plain", "--no-legend", "--no-pager")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	units, err := parseUnitList(&out)
+	if err != nil {
+		return err
+	}
+	for _, unit := range units {
+		if pattern == nil || pattern.MatchString(unit) {
+			knownUnits[unit] = true
+		}
+	}
+	return nil
+}
+
+// parseUnitList parses the output of systemctl list-units, where each line starts with the unit name
+// followed by its load, active and sub state, and returns the units that are loaded
+func parseUnitList(r io.Reader) ([]string, error) {
+	var units []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Some systemd versions mark failed units with a bullet even in plain output
+		if len(fields) > 0 && fields[0] == "●" {
+			fields = fields[1:]
+		}
+		if len(fields) < 4 || !strings.HasSuffix(fields[0], ".service") {
+			continue
+		}
+
+		// Units that are only referenced, e.g. by a dependency, have no unit file
+		if fields[1] == "not-found" {
+			continue
+		}
+		units = append(units, fields[0])
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return units, nil
+}
+
+// unitState holds the state of a unit as reported by systemctl show
+type unitState struct {
+	ActiveState string
+	SubState    string
+	Restarts    uint32
+}
+
+// getUnitStates reads the state of the given units with a single systemctl call
+func getUnitStates(units []string) (map[string]unitState, error) {
+	if len(units) == 0 {
+		return nil, nil
+	}
+
+	args := append([]string{"show", "--property=Id,ActiveState,SubState,NRestarts"}, units...)
+	cmd := exec.Command("systemctl", args...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	return parseUnitStates(&out)
+}
+
+// parseUnitStates parses the output of systemctl show, where the properties of each unit are printed as
+// key=value lines and units are separated by an empty line
+func parseUnitStates(r io.Reader) (map[string]unitState, error) {
+	states := make(map[string]unitState)
+	var unit string
+	var state unitState
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if !found {
+			if unit != "" {
+				states[unit] = state
+			}
+			unit, state = "", unitState{}
+			continue
+		}
+
+		switch key {
+		case "Id":
+			unit = value
+		case "ActiveState":
+			state.ActiveState = value
+		case "SubState":
+			state.SubState = value
+		case "NRestarts":
+			if restarts, err := strconv.ParseUint(value, 10, 32); err == nil {
+				state.Restarts = uint32(restarts)
+			}
+		}
+	}
+	if unit != "" {
+		states[unit] = state
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
@@ -0,0 +1,91 @@
+// internal/stats/systemd/stats_test.go
+
+package systemd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/therceman/gomon/internal/hostfs/hostfstest"
+)
+
+func TestParseUnitStates(t *testing.T) {
+	output := `NRestarts=0
+Id=nginx.service
+ActiveState=active
+SubState=running
+
+NRestarts=3
+Id=worker.service
+ActiveState=activating
+SubState=auto-restart
+
+NRestarts=
+Id=gone.service
+ActiveState=inactive
+SubState=dead
+`
+
+	states, err := parseUnitStates(strings.NewReader(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]unitState{
+		"nginx.service":  {ActiveState: "active", SubState: "running"},
+		"worker.service": {ActiveState: "activating", SubState: "auto-restart", Restarts: 3},
+		"gone.service":   {ActiveState: "inactive", SubState: "dead"},
+	}
+	if !reflect.DeepEqual(states, expected) {
+		t.Errorf("parseUnitStates() = %+v, expected %+v", states, expected)
+	}
+}
+
+func TestParseUnitList(t *testing.T) {
+	output := `nginx.service                loaded    active   running NGINX web server
+● worker.service             loaded    failed   failed  Queue worker
+backup.service               loaded    inactive dead    Nightly backup
+missing.service              not-found inactive dead    missing.service
+systemd-journald.socket      loaded    active   running Journal Socket
+`
+
+	units, err := parseUnitList(strings.NewReader(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"nginx.service", "worker.service", "backup.service"}
+	if !reflect.DeepEqual(units, expected) {
+		t.Errorf("parseUnitList() = %v, expected %v", units, expected)
+	}
+}
+
+func TestGetUnitStats(t *testing.T) {
+	hostfstest.FakeSys(t, map[string]string{
+		"fs/cgroup/cgroup.controllers":                        "cpu io memory pids\n",
+		"fs/cgroup/system.slice/nginx.service/cpu.stat":       "usage_usec 1000\n",
+		"fs/cgroup/system.slice/nginx.service/memory.current": "10485760\n",
+		"fs/cgroup/system.slice/nginx.service/pids.current":   "4\n",
+		"fs/cgroup/system.slice/nginx.service/io.stat":        "8:0 rbytes=100 wbytes=200\n",
+	})
+
+	stat, err := getUnitStats("nginx.service", "system.slice/nginx.service")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rates need a previous sample
+	expected := Stats{Unit: "nginx.service", Running: true, MemMB: 10, Tasks: 4}
+	if stat != expected {
+		t.Errorf("getUnitStats() = %+v, expected %+v", stat, expected)
+	}
+
+	stat, err = getUnitStats("nginx.service", "system.slice/nginx.service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stat.CPUReady {
+		t.Errorf("getUnitStats() = %+v, expected CPU to be ready on the second sample", stat)
+	}
+}
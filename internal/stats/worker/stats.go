@@ -5,21 +5,34 @@ package worker
 import (
 	"bufio"
 	"fmt"
-	"github.com/therceman/gomon/internal/helpers"
 	"os/exec"
 	"strings"
+	"time"
+
+	"github.com/therceman/gomon/internal/helpers"
+	"github.com/therceman/gomon/internal/stats/counter"
+	"github.com/therceman/gomon/internal/stats/process"
 )
 
 type Stats struct {
-	MemKB   uint32  `json:"mem_kb"`   // Used memory in KB
-	CPUPerc float32 `json:"cpu_perc"` // CPU usage percentage
-	MemPerc float32 `json:"mem_perc"` // Memory usage percentage
-	PID     uint32  `json:"pid"`      // Process ID
+	MemKB    uint32  `json:"mem_kb"`   // Used memory in KB
+	CPUReady bool    `json:"-"`        // False until a previous CPU sample is available
+	CPUPerc  float32 `json:"cpu_perc"` // CPU usage percentage
+	MemPerc  float32 `json:"mem_perc"` // Memory usage percentage
+	PID      uint32  `json:"pid"`      // Process ID
 }
 
+// cpuCounters holds the CPU time of the process in clock ticks per PID
+var cpuCounters = counter.NewSet(64)
+
 func GetStats(pidStr string, pid uint32) (Stats, error) {
-	// Get the CPU and memory usage of the process by PID
-	psCmd := exec.Command("ps", "-p", pidStr, "-o", "pid,pcpu,pmem,rss")
+	cpuReady, cpuPerc, err := getCPUStats(pidStr)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	// Get the memory usage of the process by PID
+	psCmd := exec.Command("ps", "-p", pidStr, "-o", "pid,pmem,rss")
 	psOutput, err := psCmd.Output()
 	if err != nil {
 		return Stats{}, fmt.Errorf("error executing ps command: %v", err)
@@ -31,30 +44,26 @@ func GetStats(pidStr string, pid uint32) (Stats, error) {
 
 	if scanner.Scan() {
 		fields := strings.Fields(scanner.Text())
-		if len(fields) < 4 {
+		if len(fields) < 3 {
 			return Stats{}, fmt.Errorf("unexpected format in ps output")
 		}
 
-		cpuPerc, err := helpers.ConvertStringToFloat32(fields[1])
+		memPerc, err := helpers.ConvertStringToFloat32(fields[1])
 		if err != nil {
 			return Stats{}, err
 		}
 
-		memPerc, err := helpers.ConvertStringToFloat32(fields[2])
-		if err != nil {
-			return Stats{}, err
-		}
-
-		memKB, err := helpers.ConvertStringToUint32(fields[3])
+		memKB, err := helpers.ConvertStringToUint32(fields[2])
 		if err != nil {
 			return Stats{}, err
 		}
 
 		return Stats{
-			PID:     pid,
-			CPUPerc: cpuPerc,
-			MemPerc: memPerc,
-			MemKB:   memKB,
+			PID:      pid,
+			CPUReady: cpuReady,
+			CPUPerc:  cpuPerc,
+			MemPerc:  memPerc,
+			MemKB:    memKB,
 		}, nil
 	}
 
@@ -64,3 +73,19 @@ func GetStats(pidStr string, pid uint32) (Stats, error) {
 
 	return Stats{}, fmt.Errorf("failed to read process stats")
 }
+
+// getCPUStats calculates the CPU usage percentage of the process since the previous call
+func getCPUStats(pidStr string) (bool, float32, error) {
+	// The worker PID belongs to the PID namespace of gomon, so the host procfs is not used
+	ticks, err := process.ReadCPUTicks("/proc/" + pidStr + "/stat")
+	if err != nil {
+		return false, 0, err
+	}
+
+	_, ticksPerSec, ok := cpuCounters.Update(pidStr, ticks, time.Now())
+	if !ok {
+		return false, 0, nil
+	}
+
+	return true, helpers.RoundToTwoDecimal(ticksPerSec / process.ClockTicks * 100), nil
+}
@@ -2,35 +2,110 @@
 
 package types
 
+import (
+	"regexp"
+	"time"
+
+	"github.com/therceman/gomon/internal/sketch"
+)
+
 type Config struct {
-	ContainerName         string
-	GrafanaInfluxURL      string
-	GrafanaAPIKey         string
-	GrafanaUsername       string
-	ReadTickerTimeSec     uint16
-	FlushTickerTimeSec    uint16
-	SleepBetweenFetchesMs uint16
-	MetricKeys            []string
+	ContainerName      string
+	GrafanaInfluxURL   string
+	GrafanaAPIKey      string
+	GrafanaUsername    string
+	ReadTickerTimeSec  uint16
+	FlushTickerTimeSec uint16
+	MetricKeys         []string
+	DiskMounts         []string
+	DiskIOInclude      *regexp.Regexp
+	DiskIOExclude      *regexp.Regexp
+	NetInclude         *regexp.Regexp
+	NetExclude         *regexp.Regexp
+	ContainerSockets   bool
+	HostProc           string
+	HostSys            string
+	HostRoot           string
+	Processes          []ProcessMatcher
+	SystemdUnits       *regexp.Regexp
+	Kubernetes         bool
+	KubeletURL         string
+	KubeletTokenFile   string
+	KubeletInsecureTLS bool
+	ExecCommands       []ExecCommand
+	ExecTimeoutSec     uint16
+	ExecIntervalSec    uint16
+	TextfileDir        string
+	LogPatterns        []LogPattern
+	HistogramMetrics   []string
+	HistogramBuckets   []float64
+}
+
+// LogPattern counts the log lines of containers matching a regular expression
+type LogPattern struct {
+	Container string         // Container name or ID, * for all containers
+	Name      string         // Name of the counter, reported as log_<name>
+	Pattern   *regexp.Regexp // Pattern matched against each log line
 }
 
-type Stats struct {
-	ID           string  `json:"id"`
-	Name         string  `json:"name"`
-	Group        string  `json:"group"`
-	CPUMaxPerc   float32 `json:"cpu_max_perc"`
-	CPUMinPerc   float32 `json:"cpu_min_perc"`
-	CPUAvgPerc   float32 `json:"cpu_avg_perc"`
-	CPUPercSum   float32 `json:"-"` // Used for calculating average
-	CPUCount     int     `json:"-"` // Used for calculating average
-	MemMaxMB     float32 `json:"mem_max_mb"`
-	MemMinMB     float32 `json:"mem_min_mb"`
-	MemAvgMB     float32 `json:"mem_avg_mb"`
-	MemMBPercSum float32 `json:"-"` // Used for calculating average
-	MemCount     int     `json:"-"` // Used for calculating average
-	MemMaxPerc   float32 `json:"mem_max_perc"`
-	MemMinPerc   float32 `json:"mem_min_perc"`
-	MemAvgPerc   float32 `json:"mem_avg_perc"`
-	MemPercSum   float32 `json:"-"` // Used for calculating average
-	MemPercCount int     `json:"-"` // Used for calculating average
-	DiskMB       float32 `json:"disk_mb"`
+// ExecCommand is a user supplied command whose output is parsed into metrics
+type ExecCommand struct {
+	Name    string // Name of the entry, tagged as source
+	Format  string // Output format, influx or prometheus
+	Command string // Shell command
+}
+
+// ProcessMatcher selects the processes aggregated into one process entry
+type ProcessMatcher struct {
+	Name    string         // Name of the entry
+	Kind    string         // One of name, cmdline, pidfile or cgroup
+	Value   string         // Process name, cmdline pattern, pidfile path or cgroup path depending on Kind
+	Pattern *regexp.Regexp // Compiled cmdline pattern
+}
+
+// Series is a monitored entry, e.g. the system, a container or a mount point,
+// holding the metrics aggregated within the flush window
+type Series struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Group string `json:"group"`
+	// Additional tags identifying the series, e.g. mount and device
+	Tags map[string]string `json:"tags"`
+	// Aggregated metrics keyed by name, e.g. cpu_perc
+	Metrics map[string]*Metric `json:"metrics"`
+}
+
+// MetricKind tells how the samples of a metric are to be read
+type MetricKind string
+
+const (
+	// Gauge samples are levels such as cpu_perc, a key without aggregate exports the last one
+	Gauge MetricKind = "gauge"
+	// Counter samples are increases per read tick such as log_errors, a key without aggregate exports their sum
+	Counter MetricKind = "counter"
+)
+
+// Metric holds the aggregated samples of a metric within a flush window
+type Metric struct {
+	// Kind tells whether the samples are levels or increases, it selects the value of a key without aggregate
+	Kind MetricKind `json:"kind"`
+
+	Min      float32 `json:"min"`
+	Max      float32 `json:"max"`
+	Avg      float32 `json:"avg"`
+	StdDev   float32 `json:"stddev"` // Population standard deviation
+	Sum      float32 `json:"sum"`    // Total of the samples, the increase in the window for per-tick counter increases
+	First    float32 `json:"first"`
+	Last     float32 `json:"last"`
+	Count    int     `json:"count"`
+	Duration float32 `json:"duration"` // Seconds between the first and the last sample
+	// Running mean and sum of squared differences from it, for Welford's online algorithm
+	Mean    float64   `json:"-"`
+	M2      float64   `json:"-"`
+	FirstAt time.Time `json:"-"`
+	// Distribution of the samples, only kept for the metrics selected with a percentile in METRIC_KEYS
+	Sketch *sketch.Sketch `json:"-"`
+	// Samples per histogram bucket, only kept for the metrics in HISTOGRAM_METRICS. Bucket i counts the samples
+	// above bound i-1 up to bound i of HISTOGRAM_BUCKETS, the samples above the last bound are only in Count.
+	Buckets []uint32 `json:"-"`
 }